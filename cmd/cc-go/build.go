@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+func cmdBuild(c *cli.Cmd) {
+	c.Spec = "[TARGETS...]"
+	names := c.StringsArg("TARGETS", nil, "Names of the targets to build, all targets are built by default.")
+	c.Action = func() {
+		useConfig()
+		if config == nil {
+			log.Fatalln("targets must be defined in", DefaultConfigFile, "or the config file specified with -c")
+		}
+		targets, err := selectTargets(config.Targets, *names)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scanner.LogDiagnostics()
+		for _, t := range targets {
+			if err := buildTarget(t, crumbsList); err != nil {
+				log.WithField("target", t.Name).Fatalln(err)
+			}
+			log.WithField("target", t.Name).Infoln("target has been built")
+		}
+	}
+}
+
+func selectTargets(targets []TargetConfig, names []string) ([]TargetConfig, error) {
+	if len(names) == 0 {
+		if len(targets) == 0 {
+			return nil, fmt.Errorf("no targets defined in the config file")
+		}
+		return targets, nil
+	}
+	selected := make([]TargetConfig, 0, len(names))
+	for _, name := range names {
+		var found bool
+		for _, t := range targets {
+			if t.Name == name {
+				selected = append(selected, t)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("target not found: %s", name)
+		}
+	}
+	return selected, nil
+}
+
+// buildTarget writes the target output made of the crumbs from the files matching the target.
+// Target settings take precedence over the global output options.
+func buildTarget(t TargetConfig, crumbsList [][]*parser.CodeCrumb) error {
+	if len(t.Out) == 0 && len(t.OutDir) == 0 {
+		return fmt.Errorf("output must be specified with out or out_dir")
+	}
+	excludeRxs, err := compileRxs(t.Exclude)
+	if err != nil {
+		return err
+	}
+	var targetList [][]*parser.CodeCrumb
+	for _, crumbs := range crumbsList {
+		path := crumbs[0].SourcePath
+		if len(t.Include) > 0 && !containsPrefix(path, t.Include) {
+			continue
+		} else if isMatching(path, excludeRxs) {
+			continue
+		}
+		targetList = append(targetList, crumbs)
+	}
+
+	opts := outputOptionsFromFlags()
+	if len(t.Format) > 0 {
+		opts.Format = t.Format
+	}
+	opts.File, opts.Dir = t.Out, t.OutDir
+	if len(t.Entry) > 0 {
+		opts.Entry = t.Entry
+	}
+	if len(t.Template) > 0 {
+		opts.Template = t.Template
+	}
+	if t.PerModule {
+		opts.PerModule = true
+	}
+	if err := validateOutputOptions(opts); err != nil {
+		return err
+	}
+	return writeOutput(regroupCodeCrumbs(opts.Entry, targetList), opts)
+}
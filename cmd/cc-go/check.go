@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+)
+
+func cmdCheck(c *cli.Cmd) {
+	c.Action = func() {
+		useConfig()
+		if len(*outputFile) == 0 && len(*outputDir) == 0 {
+			log.Fatalln("output file must be specified with -o or --out-dir to check against")
+		}
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scanner.LogDiagnostics()
+		files, err := generateOutput(regroupCodeCrumbs(*projectEntry, crumbsList), outputOptionsFromFlags())
+		if err != nil {
+			log.Fatalln(err)
+		}
+		paths := make([]string, 0, len(files))
+		for path := range files {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		// the ref defaults to HEAD commit, which changes once the output is committed
+		sameOutput := bytes.Equal
+		if len(*sourceRef) == 0 {
+			sameOutput = sameIgnoringCommits
+		}
+		var stale int
+		for _, path := range paths {
+			current, err := ioutil.ReadFile(path)
+			if err != nil && !os.IsNotExist(err) {
+				log.Fatalln(err)
+			}
+			if sameOutput(current, files[path]) {
+				continue
+			}
+			if diff := unifiedDiff(path, path+" (generated)", current, files[path]); len(diff) > 0 {
+				stale++
+				fmt.Print(diff)
+			}
+		}
+		var extra []string
+		if len(*outputDir) > 0 {
+			if extra, err = extraOutputFiles(*outputDir, files); err != nil {
+				log.Fatalln(err)
+			}
+			for _, path := range extra {
+				fmt.Printf("Only in %s: %s\n", *outputDir, path)
+			}
+		}
+		if stale > 0 {
+			log.Errorf("%d of %d output files are out of date, re-run cc-go to update", stale, len(paths))
+		}
+		if len(extra) > 0 {
+			log.Errorf("%d files in %s are not generated anymore, remove them", len(extra), *outputDir)
+		}
+		if stale > 0 || len(extra) > 0 {
+			os.Exit(1)
+		}
+		log.Infoln("output is up to date")
+	}
+}
+
+var commitSHARx = regexp.MustCompile(`\b[0-9a-f]{40}\b`)
+
+// sameIgnoringCommits compares outputs with commit SHAs masked, so links to different commits are equal.
+func sameIgnoringCommits(a, b []byte) bool {
+	mask := []byte("<commit>")
+	return bytes.Equal(commitSHARx.ReplaceAll(a, mask), commitSHARx.ReplaceAll(b, mask))
+}
+
+// extraOutputFiles lists files of the output directory that have the same extension as
+// the generated files but are not generated anymore, e.g. docs of removed trails.
+func extraOutputFiles(dir string, files map[string][]byte) ([]string, error) {
+	exts := make(map[string]bool)
+	for path := range files {
+		exts[filepath.Ext(path)] = true
+	}
+	var extra []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == dir {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if info.IsDir() || !exts[filepath.Ext(path)] {
+			return nil
+		}
+		if _, ok := files[path]; !ok {
+			extra = append(extra, path)
+		}
+		return nil
+	})
+	return extra, err
+}
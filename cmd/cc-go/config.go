@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultConfigFile is the config file loaded from the working directory or the closest parent
+// up to the root of the git repository, if present.
+const DefaultConfigFile = ".cc-go.yml"
+
+// Config is the project configuration, its values are used for options not specified
+// on the command line.
+type Config struct {
+	Project   string   `yaml:"project"`
+	Dir       string   `yaml:"dir"`
+	Exclude   []string `yaml:"exclude"`
+	Include   []string `yaml:"include"`
+	Entry     string   `yaml:"entry"`
+	Prefix    string   `yaml:"prefix"`
+	Ref       string   `yaml:"ref"`
+	LinkStyle string   `yaml:"link_style"`
+	Syntax    string   `yaml:"syntax"`
+	Languages []string `yaml:"languages"`
+	Template  string   `yaml:"template"`
+	PerModule bool     `yaml:"per_module"`
+
+	IncludeGenerated bool   `yaml:"include_generated"`
+	Tests            *bool  `yaml:"tests"`
+	MaxSize          string `yaml:"max_size"`
+	Tags             string `yaml:"tags"`
+	GOOS             string `yaml:"goos"`
+	GOARCH           string `yaml:"goarch"`
+
+	Targets []TargetConfig `yaml:"targets"`
+}
+
+// TargetConfig defines a document produced by cc-go build from the common scan.
+type TargetConfig struct {
+	Name      string   `yaml:"name"`
+	Include   []string `yaml:"include"`
+	Exclude   []string `yaml:"exclude"`
+	Entry     string   `yaml:"entry"`
+	Format    string   `yaml:"format"`
+	Out       string   `yaml:"out"`
+	OutDir    string   `yaml:"out_dir"`
+	Template  string   `yaml:"template"`
+	PerModule bool     `yaml:"per_module"`
+}
+
+// loadConfig reads the config file. The default config file is optional, so
+// a nil config is returned if it doesn't exist. Relative paths of the config are
+// resolved against its directory.
+func loadConfig(path string) (*Config, error) {
+	optional := len(path) == 0
+	if optional {
+		path = findDefaultConfig()
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && optional {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var cfg *Config
+	if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %v", path, err)
+	} else if cfg == nil {
+		cfg = &Config{}
+	}
+	names := make(map[string]bool, len(cfg.Targets))
+	for i, t := range cfg.Targets {
+		if len(t.Name) == 0 {
+			return nil, fmt.Errorf("config %s: target #%d has no name", path, i+1)
+		} else if names[t.Name] {
+			return nil, fmt.Errorf("config %s: duplicate target %s", path, t.Name)
+		}
+		names[t.Name] = true
+	}
+	base := filepath.Dir(path)
+	cfg.Dir = resolveConfigPath(base, cfg.Dir)
+	cfg.Template = resolveConfigPath(base, cfg.Template)
+	for i := range cfg.Targets {
+		t := &cfg.Targets[i]
+		t.Out = resolveConfigPath(base, t.Out)
+		t.OutDir = resolveConfigPath(base, t.OutDir)
+		t.Template = resolveConfigPath(base, t.Template)
+	}
+	return cfg, nil
+}
+
+// findDefaultConfig looks for the default config file in the working directory and its parents,
+// the search stops at the root of the git repository.
+func findDefaultConfig() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return DefaultConfigFile
+	}
+	for {
+		path := filepath.Join(dir, DefaultConfigFile)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return DefaultConfigFile
+}
+
+// resolveConfigPath makes the relative path of the config relative to the working directory,
+// the trailing separator of directories is kept.
+func resolveConfigPath(base, path string) string {
+	if len(path) == 0 || filepath.IsAbs(path) {
+		return path
+	}
+	resolved := filepath.Join(base, path)
+	if wd, err := os.Getwd(); err == nil && filepath.IsAbs(resolved) {
+		if rel, err := filepath.Rel(wd, resolved); err == nil {
+			resolved = rel
+		}
+	}
+	if strings.HasSuffix(path, "/") || strings.HasSuffix(path, string(filepath.Separator)) {
+		resolved += string(filepath.Separator)
+	}
+	return resolved
+}
+
+// useConfig loads the config file and applies it to the options, it's called by the commands
+// that scan the project or generate documents, so other commands don't depend on the config.
+func useConfig() {
+	cfg, err := loadConfig(*configFile)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if cfg != nil {
+		applyConfig(cfg)
+		config = cfg
+	}
+}
+
+// applyConfig fills options that haven't been specified on the command line.
+func applyConfig(cfg *Config) {
+	setDefault(projectName, cfg.Project)
+	setDefault(projectDir, cfg.Dir)
+	setDefault(projectEntry, cfg.Entry)
+	setDefault(sourcePrefix, cfg.Prefix)
+	setDefault(sourceRef, cfg.Ref)
+	setDefault(linkStyle, cfg.LinkStyle)
+	setDefault(templateDir, cfg.Template)
+	setDefault(markerSyntax, cfg.Syntax)
+	setDefault(maxFileSize, cfg.MaxSize)
+	setDefault(buildTags, cfg.Tags)
+	setDefault(buildGOOS, cfg.GOOS)
+	setDefault(buildGOARCH, cfg.GOARCH)
+	if !perModuleSet {
+		*perModule = cfg.PerModule
+	}
+	if !withGeneratedSet {
+		*withGenerated = cfg.IncludeGenerated
+	}
+	if !withTestsSet && cfg.Tests != nil {
+		*withTests = *cfg.Tests
+	}
+	if len(*excludePaths) == 0 {
+		*excludePaths = cfg.Exclude
+	}
+	if len(*includePaths) == 0 {
+		*includePaths = cfg.Include
+	}
+	if len(*languages) == 0 {
+		*languages = cfg.Languages
+	}
+}
+
+func setDefault(opt *string, value string) {
+	if len(*opt) == 0 && len(value) > 0 {
+		*opt = value
+	}
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	DiffFormatText     = "text"
+	DiffFormatJSON     = "json"
+	DiffFormatMarkdown = "markdown"
+)
+
+func cmdDiff(c *cli.Cmd) {
+	diffFormat := c.StringOpt("f format", "text", "The format of the report. Available: text, json, markdown.")
+	outputFile := c.StringOpt("o out", "", "Output file path.")
+	oldSnapshot := c.StringArg("OLD", "", "Old snapshot: a JSON output of cc-go or a git revision.")
+	newSnapshot := c.StringArg("NEW", "", "New snapshot: a JSON output of cc-go or a git revision.")
+	c.Action = func() {
+		useConfig()
+		switch *diffFormat {
+		case DiffFormatText, DiffFormatJSON, DiffFormatMarkdown:
+		default:
+			log.Fatalln("unsupported diff format:", *diffFormat)
+		}
+		var scanner *projectScanner
+		loadSnapshot := func(snapshot string) (*GroupedCodeCrumbs, error) {
+			if info, err := os.Stat(snapshot); err == nil && !info.IsDir() {
+				doc, err := readDocumentFile(snapshot)
+				if err != nil {
+					return nil, err
+				}
+				return doc.GroupedCodeCrumbs, nil
+			}
+			// not a file, so the snapshot is a git revision of the project
+			if scanner == nil {
+				scanner = newScannerFromOptions()
+			}
+			crumbsList, err := scanner.ScanRevision(snapshot)
+			if err != nil {
+				return nil, err
+			}
+			return regroupCodeCrumbs(*projectEntry, crumbsList), nil
+		}
+		oldGroups, err := loadSnapshot(*oldSnapshot)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		newGroups, err := loadSnapshot(*newSnapshot)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		diff := diffCodeCrumbs(oldGroups, newGroups)
+
+		var buf []byte
+		switch *diffFormat {
+		case DiffFormatJSON:
+			buf, err = json.MarshalIndent(diff, "", "\t")
+			if err != nil {
+				log.Fatalln(err)
+			}
+		case DiffFormatMarkdown:
+			buf = diff.Markdown(*oldSnapshot, *newSnapshot)
+		case DiffFormatText:
+			buf = diff.Text()
+		}
+		if len(*outputFile) > 0 {
+			if err := ioutil.WriteFile(*outputFile, buf, 0600); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+		fmt.Print(string(buf))
+	}
+}
+
+type CodeCrumbsDiff struct {
+	Added     []*parser.CodeCrumb `json:"added"`
+	Removed   []*parser.CodeCrumb `json:"removed"`
+	Moved     []CodeCrumbChange   `json:"moved"`
+	Edited    []CodeCrumbChange   `json:"edited"`
+	Reordered []TrailOrderChange  `json:"reordered_trails"`
+	Regrouped []TrailGroupChange  `json:"regrouped_trails"`
+}
+
+type CodeCrumbChange struct {
+	Old    *parser.CodeCrumb `json:"old"`
+	New    *parser.CodeCrumb `json:"new"`
+	Fields []string          `json:"fields,omitempty"`
+}
+
+type TrailOrderChange struct {
+	TrailID  string   `json:"trail_id"`
+	OldOrder []string `json:"old_order"`
+	NewOrder []string `json:"new_order"`
+}
+
+type TrailGroupChange struct {
+	TrailID string `json:"trail_id"`
+	From    string `json:"from"`
+	To      string `json:"to"`
+}
+
+const (
+	TrailKindMain = "main"
+	TrailKindSide = "side"
+)
+
+func (d *CodeCrumbsDiff) IsEmpty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Moved) == 0 &&
+		len(d.Edited) == 0 && len(d.Reordered) == 0 && len(d.Regrouped) == 0
+}
+
+// diffCodeCrumbs matches crumbs of two snapshots. Crumb IDs are derived from the path and line,
+// so they change whenever a crumb moves, and crumbs are matched by their trail, title and location
+// instead, in several passes from the most strict key to the least.
+func diffCodeCrumbs(oldGroups, newGroups *GroupedCodeCrumbs) *CodeCrumbsDiff {
+	diff := &CodeCrumbsDiff{}
+	oldCrumbs := allCodeCrumbs(oldGroups)
+	newCrumbs := allCodeCrumbs(newGroups)
+
+	matchKeys := []func(cc *parser.CodeCrumb) string{
+		func(cc *parser.CodeCrumb) string {
+			return fmt.Sprintf("%s\x00%s\x00%s", cc.TrailID, cc.Title, cc.SourcePath)
+		},
+		func(cc *parser.CodeCrumb) string {
+			return fmt.Sprintf("%s\x00%s", cc.TrailID, cc.Title)
+		},
+		func(cc *parser.CodeCrumb) string {
+			return fmt.Sprintf("%s\x00%s\x00%d", cc.TrailID, cc.SourcePath, cc.SourceLine)
+		},
+		func(cc *parser.CodeCrumb) string {
+			if len(cc.TrailID) == 0 {
+				return ""
+			}
+			return fmt.Sprintf("%s\x00%d\x00%s", cc.TrailID, cc.TrailStep, cc.SourcePath)
+		},
+		func(cc *parser.CodeCrumb) string {
+			return fmt.Sprintf("%s\x00%s", cc.Title, cc.SourcePath)
+		},
+	}
+	matched := make(map[*parser.CodeCrumb]*parser.CodeCrumb)
+	matchedNew := make(map[*parser.CodeCrumb]bool)
+	for _, key := range matchKeys {
+		candidates := make(map[string][]*parser.CodeCrumb)
+		for _, cc := range newCrumbs {
+			if !matchedNew[cc] {
+				if k := key(cc); len(k) > 0 {
+					candidates[k] = append(candidates[k], cc)
+				}
+			}
+		}
+		for _, cc := range oldCrumbs {
+			if _, ok := matched[cc]; ok {
+				continue
+			}
+			k := key(cc)
+			if len(k) == 0 || len(candidates[k]) == 0 {
+				continue
+			}
+			newCC := candidates[k][0]
+			candidates[k] = candidates[k][1:]
+			matched[cc] = newCC
+			matchedNew[newCC] = true
+		}
+	}
+
+	for _, oldCC := range oldCrumbs {
+		newCC, ok := matched[oldCC]
+		if !ok {
+			diff.Removed = append(diff.Removed, oldCC)
+			continue
+		}
+		if oldCC.SourcePath != newCC.SourcePath || oldCC.SourceLine != newCC.SourceLine {
+			diff.Moved = append(diff.Moved, CodeCrumbChange{
+				Old: oldCC,
+				New: newCC,
+			})
+		}
+		var fields []string
+		if oldCC.TrailID != newCC.TrailID {
+			fields = append(fields, "trail")
+		}
+		if oldCC.Title != newCC.Title {
+			fields = append(fields, "title")
+		}
+		if !reflect.DeepEqual(oldCC.DescLines, newCC.DescLines) {
+			fields = append(fields, "desc")
+		}
+		if !reflect.DeepEqual(oldCC.PeekedLines, newCC.PeekedLines) {
+			fields = append(fields, "peek")
+		}
+		if len(fields) > 0 {
+			diff.Edited = append(diff.Edited, CodeCrumbChange{
+				Old:    oldCC,
+				New:    newCC,
+				Fields: fields,
+			})
+		}
+	}
+	for _, cc := range newCrumbs {
+		if !matchedNew[cc] {
+			diff.Added = append(diff.Added, cc)
+		}
+	}
+
+	oldTrails := allTrails(oldGroups)
+	newTrails := allTrails(newGroups)
+	for _, trailID := range sortedTrailIDs(oldTrails) {
+		oldTrail := oldTrails[trailID]
+		newTrail, ok := newTrails[trailID]
+		if !ok {
+			continue
+		}
+		oldKind, newKind := trailKind(oldGroups, trailID), trailKind(newGroups, trailID)
+		if oldKind != newKind {
+			diff.Regrouped = append(diff.Regrouped, TrailGroupChange{
+				TrailID: trailID,
+				From:    oldKind,
+				To:      newKind,
+			})
+		}
+		// compare the order of crumbs that are present in both versions of the trail
+		inNewTrail := make(map[*parser.CodeCrumb]bool, len(newTrail))
+		for _, cc := range newTrail {
+			inNewTrail[cc] = true
+		}
+		var oldOrder, oldOrderMatched []*parser.CodeCrumb
+		inOldOrder := make(map[*parser.CodeCrumb]bool, len(oldTrail))
+		for _, cc := range oldTrail {
+			if newCC, ok := matched[cc]; ok && inNewTrail[newCC] {
+				oldOrder = append(oldOrder, cc)
+				oldOrderMatched = append(oldOrderMatched, newCC)
+				inOldOrder[newCC] = true
+			}
+		}
+		var newOrder []*parser.CodeCrumb
+		for _, cc := range newTrail {
+			if inOldOrder[cc] {
+				newOrder = append(newOrder, cc)
+			}
+		}
+		if !reflect.DeepEqual(oldOrderMatched, newOrder) {
+			diff.Reordered = append(diff.Reordered, TrailOrderChange{
+				TrailID:  trailID,
+				OldOrder: crumbTitles(oldOrder),
+				NewOrder: crumbTitles(newOrder),
+			})
+		}
+	}
+	return diff
+}
+
+func allCodeCrumbs(groups *GroupedCodeCrumbs) []*parser.CodeCrumb {
+	var list []*parser.CodeCrumb
+	trails := allTrails(groups)
+	for _, trailID := range sortedTrailIDs(trails) {
+		list = append(list, trails[trailID]...)
+	}
+	return append(list, groups.Remarks...)
+}
+
+func allTrails(groups *GroupedCodeCrumbs) map[string][]*parser.CodeCrumb {
+	trails := make(map[string][]*parser.CodeCrumb, len(groups.MainTrails)+len(groups.SideTrails))
+	for trailID, trail := range groups.MainTrails {
+		trails[trailID] = trail
+	}
+	for trailID, trail := range groups.SideTrails {
+		trails[trailID] = trail
+	}
+	return trails
+}
+
+func sortedTrailIDs(trails map[string][]*parser.CodeCrumb) []string {
+	ids := make([]string, 0, len(trails))
+	for trailID := range trails {
+		ids = append(ids, trailID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func trailKind(groups *GroupedCodeCrumbs, trailID string) string {
+	if _, ok := groups.MainTrails[trailID]; ok {
+		return TrailKindMain
+	}
+	return TrailKindSide
+}
+
+func crumbTitles(crumbs []*parser.CodeCrumb) []string {
+	titles := make([]string, 0, len(crumbs))
+	for _, cc := range crumbs {
+		titles = append(titles, fmt.Sprintf("#%d %s", cc.TrailStep, cc.Title))
+	}
+	return titles
+}
+
+func crumbName(cc *parser.CodeCrumb) string {
+	if len(cc.TrailID) > 0 {
+		return fmt.Sprintf("[%s#%d] %s", cc.TrailID, cc.TrailStep, cc.Title)
+	}
+	return fmt.Sprintf("[remark] %s", cc.Title)
+}
+
+func crumbLocation(cc *parser.CodeCrumb) string {
+	return fmt.Sprintf("%s:%d", cc.SourcePath, cc.SourceLine)
+}
+
+func (d *CodeCrumbsDiff) Text() []byte {
+	buf := new(bytes.Buffer)
+	if d.IsEmpty() {
+		fmt.Fprintln(buf, "No changes in codecrumbs.")
+		return buf.Bytes()
+	}
+	if len(d.Added) > 0 {
+		fmt.Fprintf(buf, "Added crumbs (%d):\n", len(d.Added))
+		for _, cc := range d.Added {
+			fmt.Fprintf(buf, "  + %s (%s)\n", crumbName(cc), crumbLocation(cc))
+		}
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(buf, "Removed crumbs (%d):\n", len(d.Removed))
+		for _, cc := range d.Removed {
+			fmt.Fprintf(buf, "  - %s (%s)\n", crumbName(cc), crumbLocation(cc))
+		}
+	}
+	if len(d.Moved) > 0 {
+		fmt.Fprintf(buf, "Moved crumbs (%d):\n", len(d.Moved))
+		for _, c := range d.Moved {
+			fmt.Fprintf(buf, "  > %s: %s -> %s\n", crumbName(c.New), crumbLocation(c.Old), crumbLocation(c.New))
+		}
+	}
+	if len(d.Edited) > 0 {
+		fmt.Fprintf(buf, "Edited crumbs (%d):\n", len(d.Edited))
+		for _, c := range d.Edited {
+			fmt.Fprintf(buf, "  * %s (%s): %s\n", crumbName(c.New), crumbLocation(c.New), strings.Join(c.Fields, ", "))
+		}
+	}
+	if len(d.Reordered) > 0 {
+		fmt.Fprintf(buf, "Reordered trails (%d):\n", len(d.Reordered))
+		for _, c := range d.Reordered {
+			fmt.Fprintf(buf, "  %s:\n    was: %s\n    now: %s\n", c.TrailID,
+				strings.Join(c.OldOrder, ", "), strings.Join(c.NewOrder, ", "))
+		}
+	}
+	if len(d.Regrouped) > 0 {
+		fmt.Fprintf(buf, "Regrouped trails (%d):\n", len(d.Regrouped))
+		for _, c := range d.Regrouped {
+			fmt.Fprintf(buf, "  %s: %s -> %s\n", c.TrailID, c.From, c.To)
+		}
+	}
+	return buf.Bytes()
+}
+
+func (d *CodeCrumbsDiff) Markdown(oldSnapshot, newSnapshot string) []byte {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "## Codecrumbs changes: `%s` → `%s`\n\n", oldSnapshot, newSnapshot)
+	if d.IsEmpty() {
+		fmt.Fprintf(buf, "No changes in codecrumbs.\n")
+		return buf.Bytes()
+	}
+	if len(d.Added) > 0 {
+		fmt.Fprintf(buf, "### Added\n\n")
+		for _, cc := range d.Added {
+			fmt.Fprintf(buf, "- %s `%s`\n", crumbName(cc), crumbLocation(cc))
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	if len(d.Removed) > 0 {
+		fmt.Fprintf(buf, "### Removed\n\n")
+		for _, cc := range d.Removed {
+			fmt.Fprintf(buf, "- %s `%s`\n", crumbName(cc), crumbLocation(cc))
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	if len(d.Moved) > 0 {
+		fmt.Fprintf(buf, "### Moved\n\n")
+		for _, c := range d.Moved {
+			fmt.Fprintf(buf, "- %s `%s` → `%s`\n", crumbName(c.New), crumbLocation(c.Old), crumbLocation(c.New))
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	if len(d.Edited) > 0 {
+		fmt.Fprintf(buf, "### Edited\n\n")
+		for _, c := range d.Edited {
+			fmt.Fprintf(buf, "- %s `%s`: %s\n", crumbName(c.New), crumbLocation(c.New), strings.Join(c.Fields, ", "))
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	if len(d.Reordered) > 0 {
+		fmt.Fprintf(buf, "### Reordered Trails\n\n")
+		for _, c := range d.Reordered {
+			fmt.Fprintf(buf, "- **%s**\n  - was: %s\n  - now: %s\n", c.TrailID,
+				strings.Join(c.OldOrder, ", "), strings.Join(c.NewOrder, ", "))
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	if len(d.Regrouped) > 0 {
+		fmt.Fprintf(buf, "### Regrouped Trails\n\n")
+		for _, c := range d.Regrouped {
+			fmt.Fprintf(buf, "- **%s**: %s → %s\n", c.TrailID, c.From, c.To)
+		}
+		fmt.Fprintf(buf, "\n")
+	}
+	return buf.Bytes()
+}
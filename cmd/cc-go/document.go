@@ -0,0 +1,137 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+// DocumentSchemaVersion is the version of JSON document layout described by
+// schema/codecrumbs.schema.json, it's increased on incompatible changes.
+const DocumentSchemaVersion = 1
+
+// CodeCrumbsDocument is the JSON representation of scanned crumbs, it can be saved
+// and used later to generate the output in any format.
+type CodeCrumbsDocument struct {
+	SchemaVersion int         `json:"schema_version"`
+	Project       ProjectInfo `json:"project"`
+
+	*GroupedCodeCrumbs
+}
+
+type ProjectInfo struct {
+	Name         string `json:"name"`
+	Entry        string `json:"entry,omitempty"`
+	SourcePrefix string `json:"source_prefix,omitempty"`
+	SourceRef    string `json:"source_ref,omitempty"`
+	SourceDir    string `json:"source_dir,omitempty"`
+}
+
+// newCodeCrumbsDocument makes the document of crumbs grouped by the entry, that may differ
+// from the global one for build targets.
+func newCodeCrumbsDocument(groups *GroupedCodeCrumbs, entry string) *CodeCrumbsDocument {
+	return &CodeCrumbsDocument{
+		SchemaVersion: DocumentSchemaVersion,
+		Project: ProjectInfo{
+			Name:         *projectName,
+			Entry:        entry,
+			SourcePrefix: *sourcePrefix,
+			SourceRef:    resolveSourceRef(),
+			SourceDir:    sourceDirPrefix,
+		},
+		GroupedCodeCrumbs: groups,
+	}
+}
+
+// readDocumentFile reads a JSON document produced by cc-go. Documents without
+// schema version made by older releases contain only the grouped crumbs.
+func readDocumentFile(path string) (*CodeCrumbsDocument, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc *CodeCrumbsDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to read JSON document %s: %v", path, err)
+	} else if doc == nil {
+		return nil, fmt.Errorf("failed to read JSON document %s: no data", path)
+	}
+	if doc.SchemaVersion > DocumentSchemaVersion {
+		return nil, fmt.Errorf("JSON document %s has unsupported schema version %d, the latest known is %d",
+			path, doc.SchemaVersion, DocumentSchemaVersion)
+	}
+	if doc.GroupedCodeCrumbs == nil {
+		doc.GroupedCodeCrumbs = &GroupedCodeCrumbs{}
+	}
+	if doc.MainTrails == nil {
+		doc.MainTrails = make(map[string][]*parser.CodeCrumb)
+	}
+	if doc.SideTrails == nil {
+		doc.SideTrails = make(map[string][]*parser.CodeCrumb)
+	}
+	return doc, nil
+}
+
+// mergeCodeCrumbs combines crumbs of several documents. A trail stays main if it's
+// main in any of the documents, crumbs are ordered the same way as after a scan.
+// Crumbs of documents with a source other than the first one keep their source for links,
+// IDs that collide with crumbs of other documents are derived anew.
+func mergeCodeCrumbs(docs []*CodeCrumbsDocument) *GroupedCodeCrumbs {
+	merged := &GroupedCodeCrumbs{
+		MainTrails: make(map[string][]*parser.CodeCrumb),
+		SideTrails: make(map[string][]*parser.CodeCrumb),
+		Remarks:    make([]*parser.CodeCrumb, 0, 100),
+	}
+	seenIDs := make(map[string]bool)
+	for i, doc := range docs {
+		var source *parser.CrumbSource
+		if project := doc.Project; !sameSource(project, docs[0].Project) {
+			source = &parser.CrumbSource{
+				Prefix: project.SourcePrefix,
+				Ref:    project.SourceRef,
+				Dir:    project.SourceDir,
+			}
+		}
+		for _, cc := range allCodeCrumbs(doc.GroupedCodeCrumbs) {
+			if cc.Source == nil {
+				cc.Source = source
+			}
+			for n := 1; seenIDs[cc.ID]; n++ {
+				cc.ID = uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s#%d.%d", cc.ID, i, n))).String()
+			}
+			seenIDs[cc.ID] = true
+		}
+	}
+	for _, doc := range docs {
+		for id, trail := range doc.MainTrails {
+			merged.MainTrails[id] = append(merged.MainTrails[id], trail...)
+		}
+		merged.Remarks = append(merged.Remarks, doc.Remarks...)
+	}
+	for _, doc := range docs {
+		for id, trail := range doc.SideTrails {
+			if _, ok := merged.MainTrails[id]; ok {
+				merged.MainTrails[id] = append(merged.MainTrails[id], trail...)
+				continue
+			}
+			merged.SideTrails[id] = append(merged.SideTrails[id], trail...)
+		}
+	}
+	for _, trail := range merged.MainTrails {
+		sort.Stable(CodeCrumbsByTrail(trail))
+	}
+	for _, trail := range merged.SideTrails {
+		sort.Stable(CodeCrumbsByTrail(trail))
+	}
+	sort.Stable(CodeCrumbsByFile(merged.Remarks))
+	return merged
+}
+
+func sameSource(a, b ProjectInfo) bool {
+	return a.SourcePrefix == b.SourcePrefix && a.SourceRef == b.SourceRef && a.SourceDir == b.SourceDir
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/generator"
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	SourceTypeJSON = "json"
+)
+
+func cmdGenerate(c *cli.Cmd) {
+	c.Spec = "[--from] FILES..."
+	formatFrom := c.StringOpt("from", "json", "Select format of the source documents. Supported: json.")
+	files := c.StringsArg("FILES", nil, "JSON documents produced by cc-go -f json, crumbs of multiple documents are merged.")
+	c.Action = func() {
+		useConfig()
+		switch *formatFrom {
+		case SourceTypeJSON:
+		default:
+			log.Fatalln("unsupported input format:", *formatFrom)
+		}
+		if err := validateOutputOptions(outputOptionsFromFlags()); err != nil {
+			log.Fatalln(err)
+		}
+
+		docs := make([]*CodeCrumbsDocument, 0, len(*files))
+		for _, file := range *files {
+			doc, err := readDocumentFile(file)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if len(docs) > 0 && (doc.Project.Name != docs[0].Project.Name || doc.Project.Entry != docs[0].Project.Entry) {
+				log.WithField("file", file).Warningln("project metadata differs from", (*files)[0], "using the first one")
+			}
+			docs = append(docs, doc)
+		}
+		// the entry specified explicitly overrides grouping of trails saved in the documents
+		regroup := len(*projectEntry) > 0
+		project := docs[0].Project
+		if len(*projectName) == 0 {
+			*projectName = project.Name
+		}
+		if len(*projectEntry) == 0 {
+			*projectEntry = project.Entry
+		}
+		if len(*sourcePrefix) == 0 {
+			*sourcePrefix = project.SourcePrefix
+		}
+		if len(*sourceRef) == 0 {
+			*sourceRef = project.SourceRef
+		}
+		if len(*sourceRef) == 0 {
+			// the document may be produced elsewhere, so the local repo is not looked up
+			*sourceRef = generator.DefaultRef
+		}
+		if *sourcePrefix == project.SourcePrefix {
+			sourceDirPrefix = project.SourceDir
+		}
+
+		groups := mergeCodeCrumbs(docs)
+		if regroup {
+			groups = regroupCodeCrumbs(*projectEntry, [][]*parser.CodeCrumb{
+				allCodeCrumbs(groups),
+			})
+		}
+		if err := writeOutput(groups, outputOptionsFromFlags()); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
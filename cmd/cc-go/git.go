@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/AtlantPlatform/codecrumbs-go/generator"
+)
+
+// findGitDir looks for the .git directory in dir and its parents. Worktrees and submodules
+// that have a .git file pointing to the actual git dir are supported too.
+func findGitDir(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		gitPath := filepath.Join(dir, ".git")
+		info, err := os.Stat(gitPath)
+		if err == nil {
+			if info.IsDir() {
+				return gitPath, nil
+			}
+			body, err := ioutil.ReadFile(gitPath)
+			if err != nil {
+				return "", err
+			}
+			line := strings.TrimSpace(string(body))
+			if !strings.HasPrefix(line, "gitdir:") {
+				return "", fmt.Errorf("unexpected contents of %s", gitPath)
+			}
+			gitDir := strings.TrimSpace(strings.TrimPrefix(line, "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return gitDir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", errors.New("not a git repository")
+		}
+		dir = parent
+	}
+}
+
+// gitDirPrefix returns the slash-separated path of dir relative to the top-level directory
+// of its working tree, like git rev-parse --show-prefix does. It's empty for the top level.
+func gitDirPrefix(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for top := dir; ; {
+		if _, err := os.Stat(filepath.Join(top, ".git")); err == nil {
+			prefix, err := filepath.Rel(top, dir)
+			if err != nil {
+				return "", err
+			}
+			if prefix == "." {
+				return "", nil
+			}
+			return filepath.ToSlash(prefix), nil
+		}
+		parent := filepath.Dir(top)
+		if parent == top {
+			return "", errors.New("not a git repository")
+		}
+		top = parent
+	}
+}
+
+// gitCommonDir returns the directory with shared refs and config, that differs from
+// the git dir in case of worktrees.
+func gitCommonDir(gitDir string) string {
+	body, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := strings.TrimSpace(string(body))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return commonDir
+}
+
+// gitHeadCommit resolves HEAD of the repository containing dir into a commit SHA.
+func gitHeadCommit(dir string) (string, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	body, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return "", err
+	}
+	head := strings.TrimSpace(string(body))
+	if !strings.HasPrefix(head, "ref:") {
+		// detached HEAD
+		return head, nil
+	}
+	ref := strings.TrimSpace(strings.TrimPrefix(head, "ref:"))
+	for _, d := range []string{gitDir, gitCommonDir(gitDir)} {
+		body, err := ioutil.ReadFile(filepath.Join(d, filepath.FromSlash(ref)))
+		if err == nil {
+			return strings.TrimSpace(string(body)), nil
+		}
+	}
+	commit, err := readPackedRef(gitCommonDir(gitDir), ref)
+	if err != nil {
+		return "", err
+	}
+	return commit, nil
+}
+
+var hexRefRx = regexp.MustCompile(`^[0-9a-f]{4,40}$`)
+
+// gitRefKind tells whether the ref is a branch, tag or commit of the repository containing dir.
+// A hex ref is taken for a commit only if there is no branch or tag with such name, other unknown
+// refs are taken for branches, e.g. ones that haven't been fetched.
+func gitRefKind(dir, ref string) (string, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	commonDir := gitCommonDir(gitDir)
+	for _, r := range []struct {
+		prefix string
+		kind   string
+	}{
+		{"refs/heads/", generator.RefKindBranch},
+		{"refs/tags/", generator.RefKindTag},
+		{"refs/remotes/origin/", generator.RefKindBranch},
+	} {
+		name := r.prefix + ref
+		for _, d := range []string{gitDir, commonDir} {
+			if _, err := os.Stat(filepath.Join(d, filepath.FromSlash(name))); err == nil {
+				return r.kind, nil
+			}
+		}
+		if _, err := readPackedRef(commonDir, name); err == nil {
+			return r.kind, nil
+		}
+	}
+	if hexRefRx.MatchString(ref) {
+		return generator.RefKindCommit, nil
+	}
+	return generator.RefKindBranch, nil
+}
+
+func readPackedRef(gitDir, ref string) (string, error) {
+	f, err := os.Open(filepath.Join(gitDir, "packed-refs"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0], nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("ref %s not found", ref)
+}
+
+// gitRemoteURL reads the URL of the named remote from the config of the repository containing dir.
+func gitRemoteURL(dir, remote string) (string, error) {
+	gitDir, err := findGitDir(dir)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(gitCommonDir(gitDir), "config"))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	section := fmt.Sprintf(`[remote "%s"]`, remote)
+	var inSection bool
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		} else if !inSection {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) == "url" {
+			return strings.TrimSpace(parts[1]), nil
+		}
+	}
+	if err := s.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("remote %s not found", remote)
+}
+
+var scpLikeURLRx = regexp.MustCompile(`^(?:[\w.-]+@)?([\w.-]+):(.+)$`)
+
+// parseRemoteURL converts a git remote URL in SSH or HTTPS form into the web URL of the
+// repository and its path (e.g. org/repo).
+func parseRemoteURL(remoteURL string) (webURL, repoPath string, err error) {
+	var host string
+	if u, err := url.Parse(remoteURL); err == nil && len(u.Scheme) > 0 && len(u.Host) > 0 {
+		host = u.Hostname()
+		repoPath = u.Path
+	} else if m := scpLikeURLRx.FindStringSubmatch(remoteURL); m != nil {
+		host = m[1]
+		repoPath = m[2]
+	} else {
+		return "", "", fmt.Errorf("unsupported remote URL: %s", remoteURL)
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	switch host {
+	case "ssh.dev.azure.com", "vs-ssh.visualstudio.com":
+		// v3/org/project/repo -> org/project/_git/repo
+		parts := strings.Split(strings.TrimPrefix(repoPath, "v3/"), "/")
+		if len(parts) != 3 {
+			return "", "", fmt.Errorf("unsupported Azure DevOps remote URL: %s", remoteURL)
+		}
+		host = "dev.azure.com"
+		repoPath = strings.Join([]string{parts[0], parts[1], "_git", parts[2]}, "/")
+	}
+	webURL = fmt.Sprintf("https://%s/%s", host, repoPath)
+	repoPath = strings.Replace(repoPath, "/_git/", "/", 1)
+	return webURL, repoPath, nil
+}
+
+func runGit(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiComment = "\x1b[90m"
+	ansiString  = "\x1b[32m"
+	ansiNumber  = "\x1b[35m"
+	ansiKeyword = "\x1b[34m"
+	ansiMarker  = "\x1b[33m"
+	ansiPeek    = "\x1b[48;5;237m"
+)
+
+var languageKeywords = map[string]string{
+	"Go": "break case chan const continue default defer else fallthrough for func go goto if import " +
+		"interface map package range return select struct switch type var nil true false",
+	"Javascript": "async await break case catch class const continue default delete do else export extends " +
+		"false finally for from function if import in instanceof let new null return super switch this throw " +
+		"true try typeof undefined var void while yield",
+	"Typescript": "async await break case catch class const continue default delete do else enum export extends " +
+		"false finally for from function if implements import in instanceof interface let namespace new null " +
+		"private protected public readonly return super switch this throw true try type typeof undefined var " +
+		"void while yield",
+	"Python": "and as assert async await break class continue def del elif else except False finally for from " +
+		"global if import in is lambda None nonlocal not or pass raise return True try while with yield",
+	"Rust": "as async await break const continue crate else enum extern false fn for if impl in let loop match " +
+		"mod move mut pub ref return self Self static struct super trait true type unsafe use where while",
+	"Ruby": "alias and begin break case class def defined do else elsif end ensure false for if in module next " +
+		"nil not or redo rescue retry return self super then true undef unless until when while yield",
+	"Java": "abstract boolean break byte case catch char class const continue default do double else enum " +
+		"extends final finally float for if implements import instanceof int interface long new null package " +
+		"private protected public return short static super switch synchronized this throw throws true false " +
+		"try void volatile while",
+	"Kotlin": "as break class continue do else false for fun if in interface is null object package return " +
+		"super this throw true try typealias val var when while",
+	"Solidity": "address bool break bytes constant continue contract else emit enum event external false for " +
+		"function if import internal library mapping memory modifier payable pragma private public pure " +
+		"require return returns storage string struct true uint uint256 view while",
+	"C/C++": "auto break case char class const continue default delete do double else enum extern false float " +
+		"for if inline int long namespace new nullptr private protected public return short signed sizeof " +
+		"static struct switch template this true typedef union unsigned using virtual void volatile while",
+	"Shell": "case do done elif else esac export fi for function if in local return then until while",
+}
+
+var keywordSets = make(map[string]map[string]bool)
+
+func keywordsFor(lang string) map[string]bool {
+	if set, ok := keywordSets[lang]; ok {
+		return set
+	}
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(languageKeywords[lang]) {
+		set[word] = true
+	}
+	keywordSets[lang] = set
+	return set
+}
+
+// lineCommentPrefixes finds which of the common line comment prefixes are recognized
+// by the comment regexps of the language.
+func lineCommentPrefixes(lang *parser.LanguageDefinition) []string {
+	var prefixes []string
+	for _, prefix := range []string{"//", "#", "--", ";", "%"} {
+		if _, ok := lang.Match([]byte(prefix + " x")); ok {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	return prefixes
+}
+
+// highlighter colours source lines with ANSI escapes. It recognizes comments, strings, numbers
+// and keywords, which is enough to read the code around a crumb in the terminal.
+type highlighter struct {
+	lang     *parser.LanguageDefinition
+	keywords map[string]bool
+	prefixes []string
+	block    *parser.BlockComment
+}
+
+func newHighlighter(langName string) *highlighter {
+	h := &highlighter{
+		keywords: keywordsFor(langName),
+	}
+	if lang, ok := parser.LanguageForName(langName); ok {
+		h.lang = lang
+		h.prefixes = lineCommentPrefixes(lang)
+	}
+	return h
+}
+
+// Line returns the highlighted line, base is the style of the whole line, e.g. a background.
+// Lines must be passed in order, so comments spanning multiple lines are tracked.
+func (h *highlighter) Line(line, base string) string {
+	buf := new(bytes.Buffer)
+	buf.WriteString(base)
+	span := func(style, text string) {
+		buf.WriteString(style)
+		buf.WriteString(text)
+		buf.WriteString(ansiReset)
+		buf.WriteString(base)
+	}
+	if h.lang == nil {
+		buf.WriteString(line)
+		buf.WriteString(ansiReset)
+		return buf.String()
+	}
+	if h.block == nil {
+		if _, ok := h.lang.Match([]byte(line)); ok {
+			span(ansiComment, line)
+			return buf.String()
+		}
+	}
+	for i := 0; i < len(line); {
+		rest := line[i:]
+		if h.block != nil {
+			end := strings.Index(rest, h.block.End)
+			if end < 0 {
+				span(ansiComment, rest)
+				break
+			}
+			end += len(h.block.End)
+			span(ansiComment, rest[:end])
+			h.block = nil
+			i += end
+			continue
+		}
+		if h.startsComment(rest) {
+			span(ansiComment, rest)
+			break
+		}
+		if block := h.startsBlock(rest); block != nil {
+			h.block = block
+			span(ansiComment, block.Start)
+			i += len(block.Start)
+			continue
+		}
+		c := rest[0]
+		switch {
+		case c == '"' || c == '\'' || c == '`':
+			end := 1
+			for end < len(rest) && rest[end] != c {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end < len(rest) {
+				end++
+			} else {
+				end = len(rest)
+			}
+			span(ansiString, rest[:end])
+			i += end
+		case isWordStart(c):
+			end := 1
+			for end < len(rest) && isWordPart(rest[end]) {
+				end++
+			}
+			if word := rest[:end]; h.keywords[word] {
+				span(ansiKeyword, word)
+			} else {
+				buf.WriteString(word)
+			}
+			i += end
+		case c >= '0' && c <= '9':
+			end := 1
+			for end < len(rest) && (isWordPart(rest[end]) || rest[end] == '.') {
+				end++
+			}
+			span(ansiNumber, rest[:end])
+			i += end
+		default:
+			buf.WriteByte(c)
+			i++
+		}
+	}
+	buf.WriteString(ansiReset)
+	return buf.String()
+}
+
+func (h *highlighter) startsComment(s string) bool {
+	for _, prefix := range h.prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *highlighter) startsBlock(s string) *parser.BlockComment {
+	for i := range h.lang.BlockComments {
+		if strings.HasPrefix(s, h.lang.BlockComments[i].Start) {
+			return &h.lang.BlockComments[i]
+		}
+	}
+	return nil
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || c >= 0x80 || unicode.IsLetter(rune(c))
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || (c >= '0' && c <= '9')
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/generator"
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+func cmdInject(c *cli.Cmd) {
+	c.Spec = "FILES..."
+	files := c.StringsArg("FILES", nil, "Markdown files with <!-- cc-go:begin --> and <!-- cc-go:end --> markers to update.")
+	c.Action = func() {
+		useConfig()
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scanner.LogDiagnostics()
+		groups := regroupCodeCrumbs(*projectEntry, crumbsList)
+		for _, file := range *files {
+			g, err := newMarkdownGenerator(outputOptionsFromFlags())
+			if err != nil {
+				log.Fatalln(err)
+			}
+			g.LinkBase = relativeLinkBase(*projectDir, file)
+			if err := injectFile(file, g, groups); err != nil {
+				log.Fatalln(err)
+			}
+		}
+	}
+}
+
+var (
+	injectBeginRx  = regexp.MustCompile(`<!--\s*cc-go:begin\b(.*?)-->`)
+	injectEndRx    = regexp.MustCompile(`<!--\s*cc-go:end\s*-->`)
+	injectOptionRx = regexp.MustCompile(`([\w-]+)(?:=("[^"]*"|\S+))?`)
+)
+
+// injectFile rewrites regions of the file between cc-go markers with the generated sections.
+// The markers are kept, so the file can be updated again.
+func injectFile(file string, g *generator.Markdown, groups *GroupedCodeCrumbs) error {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	// the TOC lists only the sections injected into the same file
+	var injected []map[string]string
+	for _, m := range injectBeginRx.FindAllSubmatchIndex(contents, -1) {
+		options, err := parseInjectOptions(string(contents[m[2]:m[3]]))
+		if err != nil {
+			return fmt.Errorf("%s: marker at line %d: %v", file, lineOf(contents, contents, m[0]), err)
+		}
+		injected = append(injected, options)
+	}
+	buf := new(bytes.Buffer)
+	var regions int
+	rest := contents
+	for {
+		begin := injectBeginRx.FindSubmatchIndex(rest)
+		if begin == nil {
+			if injectEndRx.Match(rest) {
+				return fmt.Errorf("%s: cc-go:end marker without cc-go:begin", file)
+			}
+			buf.Write(rest)
+			break
+		}
+		end := injectEndRx.FindIndex(rest[begin[1]:])
+		if end == nil {
+			return fmt.Errorf("%s: cc-go:begin marker at line %d is not closed", file, lineOf(contents, rest, begin[0]))
+		}
+		options, _ := parseInjectOptions(string(rest[begin[2]:begin[3]]))
+		section, err := renderInjectSection(g, groups, options, injected)
+		if err != nil {
+			return fmt.Errorf("%s: marker at line %d: %v", file, lineOf(contents, rest, begin[0]), err)
+		}
+		buf.Write(rest[:begin[1]])
+		buf.WriteString("\n")
+		if section = bytes.TrimSpace(section); len(section) > 0 {
+			buf.Write(section)
+			buf.WriteString("\n")
+		}
+		buf.Write(rest[begin[1]+end[0] : begin[1]+end[1]])
+		rest = rest[begin[1]+end[1]:]
+		regions++
+	}
+	if regions == 0 {
+		log.WithField("file", file).Warningln("no cc-go markers found")
+		return nil
+	}
+	if bytes.Equal(buf.Bytes(), contents) {
+		return nil
+	}
+	log.WithField("file", file).Infof("updated %d regions", regions)
+	return ioutil.WriteFile(file, buf.Bytes(), 0600)
+}
+
+// injectOptions are the names of marker options documented in renderInjectSection.
+var injectOptions = map[string]bool{"trail": true, "remarks": true, "toc": true, "intro": true}
+
+// parseInjectOptions parses options of the marker, unknown options are reported rather
+// than ignored, as the region would be rewritten with an empty section.
+func parseInjectOptions(text string) (map[string]string, error) {
+	options := make(map[string]string)
+	for _, m := range injectOptionRx.FindAllStringSubmatch(text, -1) {
+		if !injectOptions[m[1]] {
+			return nil, fmt.Errorf("unknown option %s, use trail=ID, remarks=PREFIX, toc or intro", m[1])
+		}
+		options[m[1]] = strings.Trim(m[2], `"`)
+	}
+	return options, nil
+}
+
+// renderInjectSection renders the section requested by marker options:
+//
+//	trail=ID        a specific trail
+//	remarks=PREFIX  remarks from files with the path prefix
+//	toc             the table of contents of the sections injected into the file
+//	intro           the introduction with stats
+//
+// Injected are the options of all markers in the file.
+func renderInjectSection(g *generator.Markdown, groups *GroupedCodeCrumbs, options map[string]string,
+	injected []map[string]string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, ok := options["intro"]; ok {
+		section, err := g.RenderIntro(groups.MainTrails, groups.SideTrails, groups.Remarks)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(section)
+		buf.WriteString("\n")
+	}
+	if _, ok := options["toc"]; ok {
+		mainTrails := make(map[string][]*parser.CodeCrumb)
+		sideTrails := make(map[string][]*parser.CodeCrumb)
+		var remarks []*parser.CodeCrumb
+		seen := make(map[*parser.CodeCrumb]bool)
+		for _, opts := range injected {
+			if trailID, ok := opts["trail"]; ok {
+				if trail, ok := groups.MainTrails[trailID]; ok {
+					mainTrails[trailID] = trail
+				} else if trail, ok := groups.SideTrails[trailID]; ok {
+					sideTrails[trailID] = trail
+				}
+			}
+			if prefix, ok := opts["remarks"]; ok {
+				for _, cc := range filterRemarks(groups.Remarks, prefix) {
+					if !seen[cc] {
+						seen[cc] = true
+						remarks = append(remarks, cc)
+					}
+				}
+			}
+		}
+		section, err := g.RenderTOC(mainTrails, sideTrails, remarks)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(section)
+		buf.WriteString("\n")
+	}
+	if trailID, ok := options["trail"]; ok {
+		trail, ok := groups.MainTrails[trailID]
+		if !ok {
+			trail, ok = groups.SideTrails[trailID]
+		}
+		if !ok {
+			return nil, fmt.Errorf("trail not found: %s", trailID)
+		}
+		section, err := g.RenderTrail(trailID, trail)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(section)
+	}
+	if prefix, ok := options["remarks"]; ok {
+		section, err := g.RenderRemarks(filterRemarks(groups.Remarks, prefix))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(section)
+	}
+	if len(options) == 0 {
+		return nil, fmt.Errorf("no section specified, use trail=ID, remarks=PREFIX, toc or intro")
+	}
+	return buf.Bytes(), nil
+}
+
+// filterRemarks returns remarks from files with the path prefix.
+func filterRemarks(remarks []*parser.CodeCrumb, prefix string) []*parser.CodeCrumb {
+	var filtered []*parser.CodeCrumb
+	for _, cc := range remarks {
+		if strings.HasPrefix(strings.TrimPrefix(cc.SourcePath, "/"), strings.TrimPrefix(prefix, "/")) {
+			filtered = append(filtered, cc)
+		}
+	}
+	return filtered
+}
+
+// relativeLinkBase returns a prefix for relative source links in the file,
+// so they point to the project root.
+func relativeLinkBase(projectDir, file string) string {
+	absDir, err := filepath.Abs(projectDir)
+	if err != nil {
+		return ""
+	}
+	absFile, err := filepath.Abs(file)
+	if err != nil {
+		return ""
+	}
+	rel, err := filepath.Rel(filepath.Dir(absFile), absDir)
+	if err != nil || rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel) + "/"
+}
+
+func lineOf(contents, rest []byte, offset int) int {
+	return bytes.Count(contents[:len(contents)-len(rest)+offset], []byte("\n")) + 1
+}
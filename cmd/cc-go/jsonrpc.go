@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JSON-RPC 2.0 error codes used by the language server.
+const (
+	rpcParseError     = -32700
+	rpcInvalidParams  = -32602
+	rpcMethodNotFound = -32601
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+}
+
+// IsNotification reports whether the request expects no response.
+func (r *rpcRequest) IsNotification() bool {
+	return r.ID == nil
+}
+
+type rpcResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Result  interface{}      `json:"result"`
+}
+
+type rpcErrorResponse struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id"`
+	Error   *rpcError        `json:"error"`
+}
+
+type rpcNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// rpcConn reads and writes JSON-RPC messages framed with Content-Length headers, as used by
+// the Language Server Protocol.
+type rpcConn struct {
+	r *textproto.Reader
+
+	mux sync.Mutex
+	w   io.Writer
+}
+
+func newRPCConn(r io.Reader, w io.Writer) *rpcConn {
+	return &rpcConn{
+		r: textproto.NewReader(bufio.NewReader(r)),
+		w: w,
+	}
+}
+
+// Read returns the next message, io.EOF is returned when the input is closed.
+func (c *rpcConn) Read() (*rpcRequest, error) {
+	header, err := c.r.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	size, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || size < 0 {
+		return nil, fmt.Errorf("invalid Content-Length header: %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(c.r.R, body); err != nil {
+		return nil, err
+	}
+	req := new(rpcRequest)
+	if err := json.Unmarshal(body, req); err != nil {
+		return nil, &rpcError{
+			Code:    rpcParseError,
+			Message: err.Error(),
+		}
+	}
+	return req, nil
+}
+
+func (c *rpcConn) Reply(id *json.RawMessage, result interface{}) error {
+	return c.write(&rpcResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Result:  result,
+	})
+}
+
+func (c *rpcConn) ReplyError(id *json.RawMessage, err *rpcError) error {
+	return c.write(&rpcErrorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   err,
+	})
+}
+
+func (c *rpcConn) Notify(method string, params interface{}) error {
+	return c.write(&rpcNotification{
+		JSONRPC: "2.0",
+		Method:  method,
+		Params:  params,
+	})
+}
+
+func (c *rpcConn) write(msg interface{}) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	LintFormatText  = "text"
+	LintFormatSARIF = "sarif"
+)
+
+func cmdLint(c *cli.Cmd) {
+	lintFormat := c.StringOpt("f format", LintFormatText, "The format of the report. Available: text, sarif.")
+	c.Action = func() {
+		useConfig()
+		if *lintFormat != LintFormatText && *lintFormat != LintFormatSARIF {
+			log.Fatalln("unsupported lint format:", *lintFormat)
+		}
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		problems := scanner.Problems()
+		problems = append(problems, validateEntry(*projectDir, *projectEntry, crumbsList)...)
+		problems = append(problems, validateTrails(regroupCodeCrumbs(*projectEntry, crumbsList))...)
+
+		var buf []byte
+		switch *lintFormat {
+		case LintFormatText:
+			buf = lintText(problems)
+		case LintFormatSARIF:
+			dir, err := filepath.Abs(*projectDir)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			buf, err = json.MarshalIndent(newSARIFLog(problems, dir), "", "\t")
+			if err != nil {
+				log.Fatalln(err)
+			}
+			buf = append(buf, '\n')
+		}
+		if len(*outputFile) > 0 {
+			if err := ioutil.WriteFile(*outputFile, buf, 0600); err != nil {
+				log.Fatalln(err)
+			}
+		} else {
+			fmt.Print(string(buf))
+		}
+		if len(problems) > 0 {
+			log.Errorf("found %d problems in codecrumbs", len(problems))
+			os.Exit(1)
+		}
+	}
+}
+
+func lintText(problems []crumbProblem) []byte {
+	buf := new(bytes.Buffer)
+	for _, p := range problems {
+		switch {
+		case len(p.Path) == 0:
+			fmt.Fprintf(buf, "%s [%s]\n", p.Message, p.Rule)
+		case p.Line == 0:
+			fmt.Fprintf(buf, "%s: %s [%s]\n", editorPath(p.Path, ""), p.Message, p.Rule)
+		default:
+			fmt.Fprintf(buf, "%s:%d: %s [%s]\n", editorPath(p.Path, ""), p.Line, p.Message, p.Rule)
+		}
+	}
+	return buf.Bytes()
+}
+
+// SARIF 2.1.0 log, only the properties used by cc-go are defined.
+// See https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	// sarifSourceRoot is the base of artifact locations, the project directory.
+	sarifSourceRoot = "SRCROOT"
+	// sarifFingerprint is the key of the fingerprint derived from the crumb identity.
+	sarifFingerprint = "crumbIdentity/v1"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string             `json:"id"`
+	Name                 string             `json:"name"`
+	ShortDescription     sarifMessage       `json:"shortDescription"`
+	DefaultConfiguration sarifConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             sarifMessage      `json:"message"`
+	Locations           []sarifLocation   `json:"locations,omitempty"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// newSARIFLog reports the problems as a SARIF log, locations are relative to the project directory.
+func newSARIFLog(problems []crumbProblem, dir string) *sarifLog {
+	driver := sarifDriver{
+		Name:           "cc-go",
+		InformationURI: "https://github.com/AtlantPlatform/codecrumbs-go",
+	}
+	ruleIndex := make(map[string]int, len(problemRules))
+	for i, rule := range problemRules {
+		ruleIndex[rule.ID] = i
+		driver.Rules = append(driver.Rules, sarifRule{
+			ID:   rule.ID,
+			Name: rule.Name,
+			ShortDescription: sarifMessage{
+				Text: rule.Description,
+			},
+			DefaultConfiguration: sarifConfiguration{
+				Level: rule.Level,
+			},
+		})
+	}
+	results := make([]sarifResult, 0, len(problems))
+	for _, p := range problems {
+		idx := ruleIndex[p.Rule]
+		result := sarifResult{
+			RuleID:    p.Rule,
+			RuleIndex: idx,
+			Level:     problemRules[idx].Level,
+			Message: sarifMessage{
+				Text: p.Message,
+			},
+			PartialFingerprints: map[string]string{
+				sarifFingerprint: problemFingerprint(p, dir),
+			},
+		}
+		if len(p.Path) > 0 {
+			loc := &sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{
+					URI:       strings.TrimPrefix(filepath.ToSlash(p.Path), "/"),
+					URIBaseID: sarifSourceRoot,
+				},
+			}
+			if p.Line > 0 {
+				loc.Region = &sarifRegion{
+					StartLine: p.Line,
+				}
+			}
+			result.Locations = []sarifLocation{{
+				PhysicalLocation: loc,
+			}}
+		} else if len(p.Entry) > 0 {
+			// the entrypoint doesn't exist, so it cannot be an artifact
+			result.Locations = []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{
+					Name:               path.Base(filepath.ToSlash(p.Entry)),
+					FullyQualifiedName: filepath.ToSlash(p.Entry),
+				}},
+			}}
+		}
+		results = append(results, result)
+	}
+	return &sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: driver,
+			},
+			OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+				sarifSourceRoot: {
+					URI: pathToURI(dir) + "/",
+				},
+			},
+			Results: results,
+		}},
+	}
+}
+
+// problemFingerprint identifies the problem by the identity of its crumb: the file, trail, step
+// and title, or by the contents of the line for parse errors. Unlike the crumb ID it doesn't depend
+// on the line number, so it survives unrelated edits.
+func problemFingerprint(p crumbProblem, dir string) string {
+	identity := []string{p.Rule, strings.TrimPrefix(filepath.ToSlash(p.Path), "/")}
+	switch {
+	case p.Crumb != nil:
+		cc := p.Crumb
+		identity = append(identity, cc.TrailID, fmt.Sprint(cc.TrailStep), cc.Title)
+	case p.Line > 0:
+		identity = append(identity, lineText(filepath.Join(dir, p.Path), p.Line))
+	case len(p.Path) == 0:
+		identity = append(identity, filepath.ToSlash(p.Entry))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(identity, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// lineText returns the line of the file without surrounding whitespace, empty if it cannot be read.
+func lineText(file string, line int) string {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	if line > len(lines) {
+		return ""
+	}
+	return string(bytes.TrimSpace(lines[line-1]))
+}
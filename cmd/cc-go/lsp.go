@@ -0,0 +1,585 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+func cmdLSP(c *cli.Cmd) {
+	c.Action = func() {
+		useConfig()
+		// stdout is taken by the protocol, logs go to stderr
+		log.SetOutput(os.Stderr)
+		srv := &languageServer{
+			conn:      newRPCConn(os.Stdin, os.Stdout),
+			documents: make(map[string]string),
+			diagnosed: make(map[string]bool),
+		}
+		if err := srv.Run(); err != nil {
+			log.Fatalln(err)
+		}
+		if !srv.shutdown {
+			// the client exited without the shutdown request
+			os.Exit(1)
+		}
+	}
+}
+
+// LSP structures, only the fields used by the server are defined.
+
+type lspPosition struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+type lspRange struct {
+	Start lspPosition `json:"start"`
+	End   lspPosition `json:"end"`
+}
+
+type lspLocation struct {
+	URI   string   `json:"uri"`
+	Range lspRange `json:"range"`
+}
+
+type lspDiagnostic struct {
+	Range    lspRange `json:"range"`
+	Severity int      `json:"severity"`
+	Code     string   `json:"code"`
+	Source   string   `json:"source"`
+	Message  string   `json:"message"`
+}
+
+type lspSymbolInformation struct {
+	Name          string      `json:"name"`
+	Kind          int         `json:"kind"`
+	Location      lspLocation `json:"location"`
+	ContainerName string      `json:"containerName,omitempty"`
+}
+
+type lspTextDocument struct {
+	URI  string `json:"uri"`
+	Text string `json:"text"`
+}
+
+type lspPositionParams struct {
+	TextDocument lspTextDocument `json:"textDocument"`
+	Position     lspPosition     `json:"position"`
+}
+
+const (
+	lspSeverityError   = 1
+	lspSeverityWarning = 2
+
+	lspSymbolString = 15
+	lspSymbolEvent  = 24
+
+	lspSyncFull = 1
+)
+
+// languageServer keeps an index of crumbs of the project for editors. Open documents are indexed
+// from their text in the editor, the rest of files are scanned from the disk.
+type languageServer struct {
+	conn    *rpcConn
+	dir     string
+	scanner *projectScanner
+
+	scanned [][]*parser.CodeCrumb
+	// documents are texts of the open documents by relative path
+	documents map[string]string
+	files     map[string][]*parser.CodeCrumb
+	groups    *GroupedCodeCrumbs
+	// diagnosed are the files with published diagnostics, they're cleared once fixed
+	diagnosed map[string]bool
+
+	shutdown bool
+}
+
+// Run serves requests until the exit notification or the end of input.
+func (s *languageServer) Run() error {
+	for {
+		req, err := s.conn.Read()
+		if err == io.EOF {
+			return nil
+		} else if rpcErr, ok := err.(*rpcError); ok {
+			if err := s.conn.ReplyError(nil, rpcErr); err != nil {
+				return err
+			}
+			continue
+		} else if err != nil {
+			return err
+		}
+		if req.Method == "exit" {
+			return nil
+		}
+		result, err := s.handle(req)
+		if req.IsNotification() {
+			if err != nil {
+				log.WithField("method", req.Method).Warningln(err)
+			}
+			continue
+		}
+		if err != nil {
+			rpcErr, ok := err.(*rpcError)
+			if !ok {
+				rpcErr = &rpcError{
+					Code:    rpcInternalError,
+					Message: err.Error(),
+				}
+			}
+			err = s.conn.ReplyError(req.ID, rpcErr)
+		} else {
+			err = s.conn.Reply(req.ID, result)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *languageServer) handle(req *rpcRequest) (interface{}, error) {
+	if s.scanner == nil && req.Method != "initialize" && !req.IsNotification() {
+		return nil, &rpcError{
+			Code:    -32002,
+			Message: "server is not initialized",
+		}
+	}
+	switch req.Method {
+	case "initialize":
+		var params struct {
+			RootURI  string `json:"rootUri"`
+			RootPath string `json:"rootPath"`
+		}
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.initialize(params.RootURI, params.RootPath)
+	case "initialized", "workspace/didChangeWatchedFiles":
+		return nil, s.rescan()
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen", "textDocument/didChange", "textDocument/didSave", "textDocument/didClose":
+		var params struct {
+			TextDocument   lspTextDocument `json:"textDocument"`
+			ContentChanges []struct {
+				Text string `json:"text"`
+			} `json:"contentChanges"`
+		}
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		path, ok := s.relativePath(params.TextDocument.URI)
+		if !ok || s.scanner == nil {
+			return nil, nil
+		}
+		switch req.Method {
+		case "textDocument/didOpen":
+			s.documents[path] = params.TextDocument.Text
+		case "textDocument/didChange":
+			if n := len(params.ContentChanges); n > 0 {
+				s.documents[path] = params.ContentChanges[n-1].Text
+			}
+		case "textDocument/didSave":
+			return nil, s.rescan()
+		case "textDocument/didClose":
+			delete(s.documents, path)
+			return nil, s.rescan()
+		}
+		s.reindex()
+		return nil, nil
+	case "textDocument/hover":
+		var params lspPositionParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(params), nil
+	case "textDocument/definition":
+		var params lspPositionParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(params), nil
+	case "textDocument/documentSymbol":
+		var params lspPositionParams
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		path, _ := s.relativePath(params.TextDocument.URI)
+		return s.symbols(s.files[path], ""), nil
+	case "workspace/symbol":
+		var params struct {
+			Query string `json:"query"`
+		}
+		if err := unmarshalParams(req.Params, &params); err != nil {
+			return nil, err
+		}
+		var crumbs []*parser.CodeCrumb
+		for _, path := range s.paths() {
+			crumbs = append(crumbs, s.files[path]...)
+		}
+		return s.symbols(crumbs, params.Query), nil
+	}
+	if req.IsNotification() {
+		// other notifications, e.g. $/cancelRequest, are optional
+		return nil, nil
+	}
+	return nil, &rpcError{
+		Code:    rpcMethodNotFound,
+		Message: "method not supported: " + req.Method,
+	}
+}
+
+func unmarshalParams(data json.RawMessage, v interface{}) error {
+	if len(data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return &rpcError{
+			Code:    rpcInvalidParams,
+			Message: err.Error(),
+		}
+	}
+	return nil
+}
+
+func (s *languageServer) initialize(rootURI, rootPath string) (interface{}, error) {
+	root := *projectDir
+	if len(rootURI) > 0 {
+		path, err := uriToPath(rootURI)
+		if err != nil {
+			return nil, err
+		}
+		root = path
+	} else if len(rootPath) > 0 {
+		root = rootPath
+	}
+	if len(root) == 0 {
+		root = "."
+	}
+	dir, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	// relative paths of files shouldn't start with a separator, include filters rely on that
+	s.dir = strings.TrimSuffix(dir, string(filepath.Separator)) + string(filepath.Separator)
+	*projectDir = s.dir
+	s.scanner = newScannerFromOptions()
+
+	type capabilities struct {
+		TextDocumentSync struct {
+			OpenClose bool `json:"openClose"`
+			Change    int  `json:"change"`
+			Save      bool `json:"save"`
+		} `json:"textDocumentSync"`
+		HoverProvider           bool `json:"hoverProvider"`
+		DefinitionProvider      bool `json:"definitionProvider"`
+		DocumentSymbolProvider  bool `json:"documentSymbolProvider"`
+		WorkspaceSymbolProvider bool `json:"workspaceSymbolProvider"`
+	}
+	var result struct {
+		Capabilities capabilities `json:"capabilities"`
+		ServerInfo   struct {
+			Name string `json:"name"`
+		} `json:"serverInfo"`
+	}
+	result.Capabilities.TextDocumentSync.OpenClose = true
+	result.Capabilities.TextDocumentSync.Change = lspSyncFull
+	result.Capabilities.TextDocumentSync.Save = true
+	result.Capabilities.HoverProvider = true
+	result.Capabilities.DefinitionProvider = true
+	result.Capabilities.DocumentSymbolProvider = true
+	result.Capabilities.WorkspaceSymbolProvider = true
+	result.ServerInfo.Name = "cc-go"
+	return result, nil
+}
+
+// rescan updates crumbs of the files on the disk, only changed files are parsed again.
+func (s *languageServer) rescan() error {
+	if s.scanner == nil {
+		return nil
+	}
+	crumbsList, _, err := s.scanner.Scan()
+	if err != nil {
+		return err
+	}
+	s.scanned = crumbsList
+	s.reindex()
+	return nil
+}
+
+// reindex merges the scanned files with the open documents, regroups trails and publishes diagnostics.
+func (s *languageServer) reindex() {
+	files := make(map[string][]*parser.CodeCrumb, len(s.scanned))
+	for _, crumbs := range s.scanned {
+		files[crumbs[0].SourcePath] = crumbs
+	}
+	var problems []crumbProblem
+	for _, problem := range s.scanner.Problems() {
+		if _, ok := s.documents[problem.Path]; !ok {
+			problems = append(problems, problem)
+		}
+	}
+	for path, text := range s.documents {
+		delete(files, path)
+		head := []byte(text)
+		if len(head) > fileHeadSize {
+			head = head[:fileHeadSize]
+		}
+		lang, ok := s.scanner.accept(path, func() []byte {
+			return head
+		})
+		if !ok {
+			continue
+		}
+		if _, skip := s.scanner.skipContent(path, head, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(text)), nil
+		}); skip {
+			continue
+		}
+		crumbs, err := parser.CollectCrumbs(path, lang, s.scanner.syntax, strings.NewReader(text))
+		if err == parser.ErrBinaryFile {
+			continue
+		} else if err != nil {
+			problems = append(problems, newParseProblem(path, err))
+		} else if len(crumbs) > 0 {
+			files[path] = crumbs
+		}
+	}
+	s.files = files
+
+	paths := s.paths()
+	crumbsList := make([][]*parser.CodeCrumb, 0, len(paths))
+	for _, path := range paths {
+		crumbsList = append(crumbsList, files[path])
+	}
+	tagModules(crumbsList, s.scanner.modules)
+	s.groups = regroupCodeCrumbs(*projectEntry, crumbsList)
+	problems = append(problems, validateTrails(s.groups)...)
+	s.publishDiagnostics(problems)
+}
+
+func (s *languageServer) paths() []string {
+	paths := make([]string, 0, len(s.files))
+	for path := range s.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (s *languageServer) publishDiagnostics(problems []crumbProblem) {
+	byPath := make(map[string][]lspDiagnostic)
+	for _, problem := range problems {
+		line := problem.Line - 1
+		if line < 0 {
+			line = 0
+		}
+		severity := lspSeverityWarning
+		if problem.Rule == RuleParseError {
+			severity = lspSeverityError
+		}
+		byPath[problem.Path] = append(byPath[problem.Path], lspDiagnostic{
+			Range:    lineRange(line),
+			Severity: severity,
+			Code:     problem.Rule,
+			Source:   "cc-go",
+			Message:  problem.Message,
+		})
+	}
+	for path := range s.diagnosed {
+		if _, ok := byPath[path]; !ok {
+			byPath[path] = []lspDiagnostic{}
+		}
+	}
+	paths := make([]string, 0, len(byPath))
+	for path := range byPath {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	s.diagnosed = make(map[string]bool, len(paths))
+	for _, path := range paths {
+		if len(byPath[path]) > 0 {
+			s.diagnosed[path] = true
+		}
+		err := s.conn.Notify("textDocument/publishDiagnostics", map[string]interface{}{
+			"uri":         pathToURI(filepath.Join(s.dir, path)),
+			"diagnostics": byPath[path],
+		})
+		if err != nil {
+			log.Warningln("failed to publish diagnostics:", err)
+		}
+	}
+}
+
+// crumbAt finds the crumb marked on the line of the document.
+func (s *languageServer) crumbAt(params lspPositionParams) (*parser.CodeCrumb, bool) {
+	path, ok := s.relativePath(params.TextDocument.URI)
+	if !ok {
+		return nil, false
+	}
+	for _, cc := range s.files[path] {
+		if cc.SourceLine == params.Position.Line+1 {
+			return cc, true
+		}
+	}
+	return nil, false
+}
+
+// trailOf returns the trail of the crumb, its position in the trail and whether it's the main trail.
+func (s *languageServer) trailOf(cc *parser.CodeCrumb) ([]*parser.CodeCrumb, int, bool) {
+	trail, isMain := s.groups.MainTrails[cc.TrailID]
+	if !isMain {
+		trail = s.groups.SideTrails[cc.TrailID]
+	}
+	for i, step := range trail {
+		if step == cc {
+			return trail, i, isMain
+		}
+	}
+	return nil, -1, false
+}
+
+func (s *languageServer) hover(params lspPositionParams) interface{} {
+	cc, ok := s.crumbAt(params)
+	if !ok {
+		return nil
+	}
+	buf := new(bytes.Buffer)
+	if len(cc.TrailID) == 0 {
+		fmt.Fprintf(buf, "**Remark:** %s\n", cc.Title)
+	} else if trail, pos, isMain := s.trailOf(cc); pos >= 0 {
+		kind := TrailKindSide
+		if isMain {
+			kind = TrailKindMain
+		}
+		fmt.Fprintf(buf, "**%s** · step %d of %d · %s trail\n\n", cc.TrailID, pos+1, len(trail), kind)
+		for i, step := range trail {
+			title := fmt.Sprintf("#%d %s", step.TrailStep, step.Title)
+			if i == pos {
+				fmt.Fprintf(buf, "%d. **%s**\n", i+1, title)
+				continue
+			}
+			fmt.Fprintf(buf, "%d. [%s](%s#L%d)\n", i+1, title, pathToURI(filepath.Join(s.dir, step.SourcePath)), step.SourceLine)
+		}
+	}
+	if len(cc.DescLines) > 0 {
+		fmt.Fprintf(buf, "\n%s\n", strings.Join(cc.DescLines, "\n"))
+	}
+	return map[string]interface{}{
+		"contents": map[string]string{
+			"kind":  "markdown",
+			"value": buf.String(),
+		},
+		"range": lineRange(cc.SourceLine - 1),
+	}
+}
+
+// definition jumps to the next step of the trail, the previous step is offered as well.
+func (s *languageServer) definition(params lspPositionParams) []lspLocation {
+	locations := []lspLocation{}
+	cc, ok := s.crumbAt(params)
+	if !ok || len(cc.TrailID) == 0 {
+		return locations
+	}
+	trail, pos, _ := s.trailOf(cc)
+	if pos < 0 {
+		return locations
+	}
+	if pos+1 < len(trail) {
+		locations = append(locations, s.location(trail[pos+1]))
+	}
+	if pos > 0 {
+		locations = append(locations, s.location(trail[pos-1]))
+	}
+	return locations
+}
+
+// symbols lists the crumbs matching the query by their trail or title, case-insensitively.
+func (s *languageServer) symbols(crumbs []*parser.CodeCrumb, query string) []lspSymbolInformation {
+	query = strings.ToLower(query)
+	symbols := []lspSymbolInformation{}
+	for _, cc := range crumbs {
+		name := crumbName(cc)
+		if len(query) > 0 && !strings.Contains(strings.ToLower(name), query) {
+			continue
+		}
+		kind := lspSymbolString
+		if len(cc.TrailID) > 0 {
+			kind = lspSymbolEvent
+		}
+		symbols = append(symbols, lspSymbolInformation{
+			Name:          name,
+			Kind:          kind,
+			Location:      s.location(cc),
+			ContainerName: cc.TrailID,
+		})
+	}
+	return symbols
+}
+
+func (s *languageServer) location(cc *parser.CodeCrumb) lspLocation {
+	return lspLocation{
+		URI:   pathToURI(filepath.Join(s.dir, cc.SourcePath)),
+		Range: lineRange(cc.SourceLine - 1),
+	}
+}
+
+// relativePath converts the document URI into the path relative to the project, documents
+// outside of the project are ignored.
+func (s *languageServer) relativePath(uri string) (string, bool) {
+	path, err := uriToPath(uri)
+	if err != nil || !strings.HasPrefix(path, s.dir) {
+		return "", false
+	}
+	return strings.TrimPrefix(path, s.dir), true
+}
+
+// lineRange spans the whole line, so the line length is not needed.
+func lineRange(line int) lspRange {
+	return lspRange{
+		Start: lspPosition{Line: line},
+		End:   lspPosition{Line: line + 1},
+	}
+}
+
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		// Windows paths start with a drive letter
+		path = "/" + path
+	}
+	u := &url.URL{
+		Scheme: "file",
+		Path:   path,
+	}
+	return u.String()
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	} else if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported URI scheme: %s", u.Scheme)
+	}
+	path := u.Path
+	if runtime.GOOS == "windows" {
+		path = strings.TrimPrefix(path, "/")
+	}
+	return filepath.FromSlash(path), nil
+}
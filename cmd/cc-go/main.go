@@ -2,12 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"go/build"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	cli "github.com/jawher/mow.cli"
 	log "github.com/sirupsen/logrus"
@@ -26,111 +30,92 @@ var app = cli.App("cc-go", "Learn, design or document codebase by putting breadc
 `)
 
 var (
-	projectName  = app.StringOpt("p project", "ProjectName", "Specify project prefix on GitHub (for GFM) or just a name.")
-	projectDir   = app.StringOpt("d dir", "", "Project directory path containing augmented source code.")
-	excludePaths = app.StringsOpt("exclude", nil, "Exclude specfic path prefixes (e.g. vendor).")
-	includePaths = app.StringsOpt("include", nil, "Include path prefixes.")
-	projectEntry = app.StringOpt("e entry", "", "Entrypoint file that is likely the source of main codecrumbs trails.")
-	sourcePrefix = app.StringOpt("prefix", "", "Source prefix for the file paths referenced in the documentation.")
-	outputFormat = app.StringOpt("f format", "markdown", "The format of output to produce. Available: markdown, json.")
-	outputFile   = app.StringOpt("o out", "", "Output file path.")
+	projectName   = app.StringOpt("p project", "", "Specify project prefix on GitHub (for GFM) or just a name. Detected from git remote by default.")
+	projectDir    = app.StringOpt("d dir", "", "Project directory path containing augmented source code.")
+	excludePaths  = app.StringsOpt("exclude", nil, "Exclude specfic path prefixes (e.g. vendor).")
+	includePaths  = app.StringsOpt("include", nil, "Include path prefixes.")
+	projectEntry  = app.StringOpt("e entry", "", "Entrypoint file that is likely the source of main codecrumbs trails.")
+	sourcePrefix  = app.StringOpt("prefix", "", "Source prefix for the file paths referenced in the documentation. Detected from git remote by default.")
+	sourceRef     = app.StringOpt("ref", "", "Branch, tag or commit SHA used in source links. Defaults to HEAD commit of the local git repo.")
+	linkStyle     = app.StringOpt("link-style", "", "Style of source links, detected from the prefix by default. Available: "+strings.Join(generator.LinkStyles, ", ")+".")
+	linkTemplate  = app.StringOpt("link-template", "", "Custom source link template, e.g. {{.Prefix}}/blob/{{.Ref}}/{{.Path}}#L{{.Line}}")
+	outputFormat  = app.StringOpt("f format", "markdown", "The format of output to produce. Available: markdown, json, ctags, quickfix.")
+	outputFile    = app.StringOpt("o out", "", "Output file path.")
+	outputDir     = app.StringOpt("out-dir", "", "Output directory for split Markdown: an index, a file per trail and remarks.")
+	perModule     = app.Bool(cli.BoolOpt{Name: "per-module", Desc: "Write a document per Go module and an index of modules into --out-dir.", SetByUser: &perModuleSet})
+	watchMode     = app.BoolOpt("w watch", false, "Keep running and regenerate the output when crumbs in source files change.")
+	watchInterval = app.StringOpt("watch-interval", "1s", "Interval of polling for changes in watch mode.")
+	templateDir   = app.StringOpt("template", "", "Directory with *.tmpl files overriding document, trail, step and remark partials of Markdown output.")
+	markerSyntax  = app.StringOpt("syntax", "", "Syntax of codecrumb markers, native by default. Available: "+strings.Join(parser.Syntaxes, ", ")+".")
+	languages     = app.StringsOpt("lang", nil, "Scan only files in the specified languages (e.g. Go).")
+	withGenerated = app.Bool(cli.BoolOpt{Name: "include-generated", Desc: "Scan generated files with the standard \"Code generated ... DO NOT EDIT.\" header.", SetByUser: &withGeneratedSet})
+	withTests     = app.Bool(cli.BoolOpt{Name: "tests", Value: true, Desc: "Scan test files, use --tests=false to skip them.", SetByUser: &withTestsSet})
+	maxFileSize   = app.StringOpt("max-size", "", "Skip files larger than the size, e.g. 512KB or 1MB.")
+	buildTags     = app.StringOpt("tags", "", "Comma-separated Go build tags, Go files are filtered by build constraints if set.")
+	buildGOOS     = app.StringOpt("goos", "", "GOOS used to evaluate build constraints of Go files.")
+	buildGOARCH   = app.StringOpt("goarch", "", "GOARCH used to evaluate build constraints of Go files.")
+	configFile    = app.StringOpt("c config", "", "Config file path, "+DefaultConfigFile+" in the working directory or its parents up to the repository root is loaded by default.")
 )
 
+// config is the loaded config file, if any.
+var config *Config
+
+// bool options that are set on the command line explicitly, config doesn't override them.
+var perModuleSet, withGeneratedSet, withTestsSet bool
+
 const (
 	OutputFormatMarkdown = "markdown"
 	OutputFormatJSON     = "json"
+	OutputFormatCtags    = "ctags"
+	OutputFormatQuickfix = "quickfix"
 )
 
 func main() {
 	app.Command("render", "Renders generated files into some representation (e.g. Markdown -> HTML)", cmdRender)
+	app.Command("check", "Checks that the output file is up to date with the source code", cmdCheck)
+	app.Command("diff", "Reports changes of codecrumbs between two snapshots (JSON outputs or git revisions)", cmdDiff)
+	app.Command("inject", "Injects generated sections into existing Markdown files between cc-go markers", cmdInject)
+	app.Command("generate", "Generates the output from JSON documents saved with -f json, without scanning the sources", cmdGenerate)
+	app.Command("serve", "Serves live preview of the documentation, re-rendered when source files change", cmdServe)
+	app.Command("build", "Builds all targets defined in the config file from a single scan", cmdBuild)
+	app.Command("query", "Searches codecrumbs by trail, path, language, text and kind", cmdQuery)
+	app.Command("tour", "Walks through a trail step by step in the terminal, commands such as n, p or q are confirmed with Enter", cmdTour)
+	app.Command("lsp", "Runs the Language Server Protocol server on stdio for editor integration", cmdLSP)
+	app.Command("lint", "Reports parse errors and broken trails as text or SARIF", cmdLint)
+	app.Command("stats", "Reports documentation coverage by directory: crumbs, lines of code and undocumented Go exports", cmdStats)
 	app.Action = func() {
-		if len(*projectDir) == 0 {
-			log.Fatalln("project directory must be specified with -d or --dir")
-		}
-		if len(*projectEntry) == 0 {
-			log.Warningln("project entrypoint file should be specified with -e or --entry")
-		}
-		switch *outputFormat {
-		case OutputFormatMarkdown, OutputFormatJSON:
-		default:
-			log.Fatalln("unsupported output format:", *outputFormat)
+		useConfig()
+		if err := validateOutputOptions(outputOptionsFromFlags()); err != nil {
+			log.Fatalln(err)
 		}
-
-		crumbsList := make([][]*parser.CodeCrumb, 0)
-		excludeRxs, err := compileRxs(*excludePaths)
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
 		if err != nil {
 			log.Fatalln(err)
 		}
-		err = filepath.Walk(*projectDir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			relativePath := strings.TrimPrefix(path, *projectDir)
-			isIncluded := containsPrefix(relativePath, *includePaths)
-			if info.IsDir() {
-				if isMatching(relativePath, excludeRxs) {
-					return filepath.SkipDir
-				}
-				return nil
-			} else if isMatching(relativePath, excludeRxs) {
-				return nil
-			}
-			if len(*includePaths) > 0 && !isIncluded {
-				return nil
-			}
-
-			commentLineLang, ok := parser.LanguageFor(filepath.Ext(path))
-			if !ok {
-				return nil
-			}
-			f, err := os.Open(path)
-			if err != nil {
-				log.Warningln(err)
-				return nil
-			}
-			defer f.Close()
-			crumbs, err := parser.CollectCrumbs(relativePath, commentLineLang, f)
-			if err != nil {
-				log.WithFields(log.Fields{
-					"file": relativePath,
-				}).Warningln(err)
-				return nil
-			} else if len(crumbs) > 0 {
-				crumbsList = append(crumbsList, crumbs)
-			}
-			return nil
-		})
-		if err != nil {
+		scanner.LogDiagnostics()
+		if err := writeOutput(regroupCodeCrumbs(*projectEntry, crumbsList), outputOptionsFromFlags()); err != nil {
 			log.Fatalln(err)
 		}
-		groups := regroupCodeCrumbs(*projectEntry, crumbsList)
-
-		var buf []byte
-		switch *outputFormat {
-		case OutputFormatJSON:
-			buf, err = json.MarshalIndent(groups, "", "\t")
-			if err != nil {
-				log.Fatalln(err)
-			}
-		case OutputFormatMarkdown:
-			g := generator.NewMarkdownGenerator(*projectName, *projectEntry, *sourcePrefix)
-			buf, err = g.RenderDocument(
-				groups.MainTrails,
-				groups.SideTrails,
-				groups.Remarks,
-			)
+		if !*watchMode {
+			return
+		}
+		log.Infoln("watching for changes in", *projectDir)
+		for range time.Tick(watchIntervalFromOptions()) {
+			crumbsList, changed, err := scanner.Scan()
 			if err != nil {
-				log.Fatalln(err)
+				log.Errorln(err)
+				continue
+			} else if !changed {
+				continue
 			}
-		}
-		if len(*outputFile) > 0 {
-			if err := ioutil.WriteFile(*outputFile, buf, 0600); err != nil {
-				log.Fatalln(err)
+			scanner.LogDiagnostics()
+			if err := writeOutput(regroupCodeCrumbs(*projectEntry, crumbsList), outputOptionsFromFlags()); err != nil {
+				log.Errorln(err)
+				continue
 			}
-			return
+			log.Infoln("output has been regenerated")
 		}
-		fmt.Println(string(buf))
 	}
 	if err := app.Run(os.Args); err != nil {
 		log.Fatalln(err)
@@ -153,6 +138,7 @@ func cmdRender(c *cli.Cmd) {
 	inputFile := c.StringArg("FILE", "", "Input file to read, must be in specified format (e.g. markdown).")
 	ghClientID := c.StringOpt("client-id", "", "GitHub Client ID for Authorization of requests.")
 	ghClientSecret := c.StringOpt("client-secret", "", "GitHub Client Secret for Authorization of requests.")
+	selfContained := c.BoolOpt("self-contained", false, "Inline fonts, styles and images as data URIs, so the page works offline.")
 	c.Action = func() {
 		switch *formatFrom {
 		case SourceTypeMarkdown:
@@ -177,6 +163,12 @@ func cmdRender(c *cli.Cmd) {
 			if err != nil {
 				log.Fatalln(err)
 			}
+			if *selfContained {
+				buf, err = newInliner(filepath.Dir(*inputFile)).InlineHTML(buf)
+				if err != nil {
+					log.Fatalln(err)
+				}
+			}
 			if len(*outputFile) == 0 {
 				*outputFile = *inputFile + ".html"
 			}
@@ -189,6 +181,12 @@ func cmdRender(c *cli.Cmd) {
 			if err != nil {
 				log.Fatalln(err)
 			}
+			if *selfContained {
+				buf, err = newInliner(filepath.Dir(*inputFile)).InlineHTML(buf)
+				if err != nil {
+					log.Fatalln(err)
+				}
+			}
 			if len(*outputFile) == 0 {
 				*outputFile = *inputFile + ".html"
 			}
@@ -199,6 +197,348 @@ func cmdRender(c *cli.Cmd) {
 	}
 }
 
+func newInliner(baseDir string) *renderer.Inliner {
+	in := renderer.NewInliner(baseDir)
+	in.Warn = func(ref string, err error) {
+		log.WithField("ref", ref).Warningln("failed to inline resource:", err)
+	}
+	return in
+}
+
+// sourceDirPrefix is the path of the project directory in the git repository, it's set
+// along with the detected source prefix, so links point to the right files.
+var sourceDirPrefix string
+
+// detectProjectFromGit fills the project name and the source prefix, if they are not
+// specified, using the origin remote of the git repository.
+func detectProjectFromGit() {
+	var webURL, repoPath string
+	remoteURL, err := gitRemoteURL(*projectDir, "origin")
+	if err == nil {
+		webURL, repoPath, err = parseRemoteURL(remoteURL)
+	}
+	if err != nil {
+		log.WithError(err).Debugln("failed to detect git remote")
+	}
+	if len(*projectName) == 0 {
+		if len(repoPath) > 0 {
+			*projectName = repoPath
+		} else if dir, err := filepath.Abs(*projectDir); err == nil {
+			*projectName = filepath.Base(dir)
+		}
+	}
+	if len(*sourcePrefix) == 0 && len(webURL) > 0 {
+		*sourcePrefix = webURL
+		if sourceDirPrefix, err = gitDirPrefix(*projectDir); err != nil {
+			log.WithError(err).Debugln("failed to detect the project directory in git repository")
+		}
+	}
+}
+
+// newScannerFromOptions validates the project options and creates a scanner for the project.
+func newScannerFromOptions() *projectScanner {
+	if len(*projectDir) == 0 {
+		log.Fatalln("project directory must be specified with -d or --dir")
+	}
+	if len(*projectEntry) == 0 {
+		log.Warningln("project entrypoint file should be specified with -e or --entry")
+	}
+	if len(*projectName) == 0 || len(*sourcePrefix) == 0 {
+		detectProjectFromGit()
+	}
+	excludeRxs, err := compileRxs(*excludePaths)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	syntax, err := parser.ParseSyntax(*markerSyntax)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	for _, name := range *languages {
+		if !supportedLanguage(name) {
+			log.Fatalln("unsupported language:", name)
+		}
+	}
+	maxSize, err := parseSize(*maxFileSize)
+	if err != nil {
+		log.Fatalln("failed to parse max file size:", err)
+	}
+	return newProjectScanner(*projectDir, scanOptions{
+		includes:  *includePaths,
+		excludes:  excludeRxs,
+		syntax:    syntax,
+		languages: *languages,
+
+		skipGenerated: !*withGenerated,
+		skipTests:     !*withTests,
+		maxSize:       maxSize,
+		buildContext:  buildContextFromOptions(),
+	})
+}
+
+// outputOptions select the format and the destination of the generated output,
+// the build targets have their own ones.
+type outputOptions struct {
+	Format    string
+	File      string
+	Dir       string
+	Entry     string
+	Template  string
+	PerModule bool
+}
+
+// outputOptionsFromFlags returns output options specified on the command line or in the config.
+func outputOptionsFromFlags() outputOptions {
+	return outputOptions{
+		Format:    *outputFormat,
+		File:      *outputFile,
+		Dir:       *outputDir,
+		Entry:     *projectEntry,
+		Template:  *templateDir,
+		PerModule: *perModule,
+	}
+}
+
+func validateOutputOptions(opts outputOptions) error {
+	switch opts.Format {
+	case OutputFormatMarkdown, OutputFormatJSON, OutputFormatCtags, OutputFormatQuickfix:
+	default:
+		return fmt.Errorf("unsupported output format: %s", opts.Format)
+	}
+	if len(opts.Dir) > 0 && opts.Format != OutputFormatMarkdown && opts.Format != OutputFormatQuickfix {
+		return errors.New("split output with --out-dir is supported only for markdown and quickfix formats")
+	}
+	if opts.PerModule && opts.Format != OutputFormatMarkdown {
+		return errors.New("output per module is supported only for markdown format")
+	}
+	if opts.PerModule && len(opts.Dir) == 0 {
+		return errors.New("output directory must be specified with --out-dir for the output per module")
+	}
+	return nil
+}
+
+// buildContextFromOptions returns the context to evaluate build constraints of Go files,
+// or nil if none of --tags, --goos and --goarch are specified.
+func buildContextFromOptions() *build.Context {
+	if len(*buildTags) == 0 && len(*buildGOOS) == 0 && len(*buildGOARCH) == 0 {
+		return nil
+	}
+	ctx := build.Default
+	ctx.CgoEnabled = true
+	ctx.BuildTags = nil
+	for _, tag := range strings.Split(*buildTags, ",") {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			ctx.BuildTags = append(ctx.BuildTags, tag)
+		}
+	}
+	if len(*buildGOOS) > 0 {
+		ctx.GOOS = *buildGOOS
+	}
+	if len(*buildGOARCH) > 0 {
+		ctx.GOARCH = *buildGOARCH
+	}
+	return &ctx
+}
+
+var sizeRx = regexp.MustCompile(`^(\d+)\s*([KMG]?)B?$`)
+
+// parseSize parses the size in bytes with an optional K, M or G suffix. The empty size means no limit.
+func parseSize(size string) (int64, error) {
+	if len(size) == 0 {
+		return 0, nil
+	}
+	m := sizeRx.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(size)))
+	if m == nil {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+	n, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	switch m[2] {
+	case "K":
+		n <<= 10
+	case "M":
+		n <<= 20
+	case "G":
+		n <<= 30
+	}
+	return n, nil
+}
+
+func watchIntervalFromOptions() time.Duration {
+	interval, err := time.ParseDuration(*watchInterval)
+	if err != nil {
+		log.Fatalln("failed to parse watch interval:", err)
+	}
+	return interval
+}
+
+// writeOutput generates the output in the selected format and writes it into the file,
+// the output directory or stdout.
+func writeOutput(groups *GroupedCodeCrumbs, opts outputOptions) error {
+	files, err := generateOutput(groups, opts)
+	if err != nil {
+		return err
+	}
+	for path, buf := range files {
+		if len(path) == 0 {
+			fmt.Println(string(buf))
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, buf, 0600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateOutput renders the output in the selected format, keyed by paths of the files to write.
+// The path is empty if the output should be printed to stdout.
+func generateOutput(groups *GroupedCodeCrumbs, opts outputOptions) (map[string][]byte, error) {
+	var buf []byte
+	var err error
+	switch opts.Format {
+	case OutputFormatJSON:
+		buf, err = json.MarshalIndent(newCodeCrumbsDocument(groups, opts.Entry), "", "\t")
+		if err != nil {
+			return nil, err
+		}
+	case OutputFormatCtags:
+		buf = ctagsFile(groups, filepath.Dir(opts.File))
+	case OutputFormatQuickfix:
+		lists, order := quickfixLists(groups)
+		if len(opts.Dir) > 0 {
+			// a list per trail, e.g. :cfile out/auth.qf
+			names := quickfixFiles(order)
+			files := make(map[string][]byte, len(lists))
+			for name, list := range lists {
+				files[filepath.Join(opts.Dir, names[name])] = list
+			}
+			return files, nil
+		}
+		for _, name := range order {
+			buf = append(buf, lists[name]...)
+		}
+	case OutputFormatMarkdown:
+		g, err := newMarkdownGenerator(opts)
+		if err != nil {
+			return nil, err
+		}
+		if len(opts.Dir) > 0 {
+			render := g.RenderSplit
+			if opts.PerModule {
+				render = g.RenderModules
+			}
+			split, err := render(
+				groups.MainTrails,
+				groups.SideTrails,
+				groups.Remarks,
+			)
+			if err != nil {
+				return nil, err
+			}
+			files := make(map[string][]byte, len(split))
+			for name, buf := range split {
+				files[filepath.Join(opts.Dir, filepath.FromSlash(name))] = buf
+			}
+			return files, nil
+		}
+		buf, err = g.RenderDocument(
+			groups.MainTrails,
+			groups.SideTrails,
+			groups.Remarks,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return map[string][]byte{
+		opts.File: buf,
+	}, nil
+}
+
+func newMarkdownGenerator(opts outputOptions) (*generator.Markdown, error) {
+	g := generator.NewMarkdownGenerator(*projectName, opts.Entry, *sourcePrefix)
+	var err error
+	if g.Linker, err = newSourceLinker(); err != nil {
+		return nil, err
+	}
+	if len(opts.Template) > 0 {
+		if err := g.LoadTemplates(opts.Template); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+func newSourceLinker() (*generator.SourceLinker, error) {
+	ref := resolveSourceRef()
+	prefix := *sourcePrefix
+	style := *linkStyle
+	if len(style) == 0 {
+		style = generator.DetectLinkStyle(prefix)
+	}
+	if len(prefix) == 0 && (style == generator.LinkStyleFile || style == generator.LinkStyleVSCode) {
+		dir, err := filepath.Abs(*projectDir)
+		if err != nil {
+			return nil, err
+		}
+		prefix = filepath.ToSlash(dir)
+	}
+	var linker *generator.SourceLinker
+	var err error
+	if len(*linkTemplate) > 0 {
+		linker, err = generator.NewSourceLinkerTemplate(prefix, ref, style, *linkTemplate)
+	} else {
+		linker, err = generator.NewSourceLinker(prefix, ref, style)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if prefix == *sourcePrefix {
+		linker.Dir = sourceDirPrefix
+	}
+	switch {
+	case len(ref) == 0, ref == generator.DefaultRef:
+	case len(*sourceRef) == 0:
+		// HEAD commit
+		linker.RefKind = generator.RefKindCommit
+	default:
+		if kind, err := gitRefKind(*projectDir, ref); err == nil {
+			linker.RefKind = kind
+		} else {
+			log.WithError(err).Debugln("failed to detect the kind of ref, guessing by its name")
+		}
+	}
+	return linker, nil
+}
+
+// resolveSourceRef returns the ref specified with --ref or the HEAD commit of the local git repo.
+func resolveSourceRef() string {
+	if len(*sourceRef) > 0 {
+		return *sourceRef
+	}
+	commit, err := gitHeadCommit(*projectDir)
+	if err != nil {
+		log.WithError(err).Debugln("failed to detect HEAD commit")
+		return ""
+	}
+	return commit
+}
+
+func supportedLanguage(name string) bool {
+	for _, def := range parser.SupportedLangs {
+		if strings.EqualFold(def.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
 func isMatching(path string, rxs []*regexp.Regexp) bool {
 	for _, rx := range rxs {
 		if rx.MatchString(path) {
@@ -45,13 +45,14 @@ func regroupCodeCrumbs(entryPoint string, crumbsList [][]*parser.CodeCrumb) *Gro
 			)
 		}
 	}
+	// stable sorting keeps the output deterministic for crumbs with equal keys
 	for _, trail := range grouped.MainTrails {
-		sort.Sort(CodeCrumbsByTrail(trail))
+		sort.Stable(CodeCrumbsByTrail(trail))
 	}
 	for _, trail := range grouped.SideTrails {
-		sort.Sort(CodeCrumbsByTrail(trail))
+		sort.Stable(CodeCrumbsByTrail(trail))
 	}
-	sort.Sort(CodeCrumbsByFile(grouped.Remarks))
+	sort.Stable(CodeCrumbsByFile(grouped.Remarks))
 	return grouped
 }
 
@@ -60,7 +61,10 @@ type CodeCrumbsByFile []*parser.CodeCrumb
 func (s CodeCrumbsByFile) Len() int      { return len(s) }
 func (s CodeCrumbsByFile) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
 func (s CodeCrumbsByFile) Less(i, j int) bool {
-	return s[i].SourcePath < s[j].SourcePath && s[i].SourceLine < s[j].SourceLine
+	if s[i].SourcePath != s[j].SourcePath {
+		return s[i].SourcePath < s[j].SourcePath
+	}
+	return s[i].SourceLine < s[j].SourceLine
 }
 
 type CodeCrumbsByTrail []*parser.CodeCrumb
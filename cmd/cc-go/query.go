@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	QueryFormatTable = "table"
+	QueryFormatJSON  = "json"
+	QueryFormatLines = "lines"
+)
+
+const KindRemark = "remark"
+
+func cmdQuery(c *cli.Cmd) {
+	inputFile := c.StringOpt("i input", "", "Query a JSON document saved with -f json instead of scanning the project.")
+	trails := c.StringsOpt("trail", nil, "Trail ID glob, e.g. auth-*.")
+	paths := c.StringsOpt("path", nil, "Source path prefix, e.g. internal/.")
+	langs := c.StringsOpt("language", nil, "Language name, e.g. Go.")
+	texts := c.StringsOpt("text", nil, "Regexp matching the title or description.")
+	kinds := c.StringsOpt("kind", nil, "Kind of crumbs: main, side or remark.")
+	matchAny := c.BoolOpt("or", false, "Match crumbs satisfying any of the filters, by default all filters must match.")
+	queryFormat := c.StringOpt("f format", QueryFormatTable, "The format of results. Available: table, json, lines (file:line: title).")
+	c.Action = func() {
+		useConfig()
+		switch *queryFormat {
+		case QueryFormatTable, QueryFormatJSON, QueryFormatLines:
+		default:
+			log.Fatalln("unsupported query format:", *queryFormat)
+		}
+		q, err := newCrumbsQuery(*trails, *paths, *langs, *texts, *kinds, *matchAny)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		var groups *GroupedCodeCrumbs
+		if len(*inputFile) > 0 {
+			doc, err := readDocumentFile(*inputFile)
+			if err != nil {
+				log.Fatalln(err)
+			}
+			groups = doc.GroupedCodeCrumbs
+		} else {
+			scanner := newScannerFromOptions()
+			crumbsList, _, err := scanner.Scan()
+			if err != nil {
+				log.Fatalln(err)
+			}
+			scanner.LogDiagnostics()
+			groups = regroupCodeCrumbs(*projectEntry, crumbsList)
+		}
+		results := q.Run(groups)
+
+		var buf []byte
+		switch *queryFormat {
+		case QueryFormatJSON:
+			buf, err = json.MarshalIndent(results, "", "\t")
+			if err != nil {
+				log.Fatalln(err)
+			}
+			buf = append(buf, '\n')
+		case QueryFormatLines:
+			buf = results.Lines(*projectDir)
+		case QueryFormatTable:
+			buf = results.Table()
+		}
+		if len(*outputFile) > 0 {
+			if err := ioutil.WriteFile(*outputFile, buf, 0600); err != nil {
+				log.Fatalln(err)
+			}
+			return
+		}
+		fmt.Print(string(buf))
+	}
+}
+
+// crumbsQuery filters crumbs by their fields. Multiple values of the same filter are alternatives,
+// different filters are combined with AND, or with OR if matchAny is set.
+type crumbsQuery struct {
+	trails   []string
+	paths    []string
+	langs    []string
+	texts    []*regexp.Regexp
+	kinds    []string
+	matchAny bool
+}
+
+func newCrumbsQuery(trails, paths, langs, texts, kinds []string, matchAny bool) (*crumbsQuery, error) {
+	for _, glob := range trails {
+		if _, err := path.Match(glob, ""); err != nil {
+			return nil, fmt.Errorf("invalid trail pattern %s: %v", glob, err)
+		}
+	}
+	for _, kind := range kinds {
+		switch kind {
+		case TrailKindMain, TrailKindSide, KindRemark:
+		default:
+			return nil, fmt.Errorf("unknown kind: %s", kind)
+		}
+	}
+	textRxs, err := compileRxs(texts)
+	if err != nil {
+		return nil, err
+	}
+	return &crumbsQuery{
+		trails:   trails,
+		paths:    paths,
+		langs:    langs,
+		texts:    textRxs,
+		kinds:    kinds,
+		matchAny: matchAny,
+	}, nil
+}
+
+type QueryResult struct {
+	Kind string `json:"kind"`
+
+	*parser.CodeCrumb
+}
+
+type QueryResults []QueryResult
+
+// Run returns crumbs matching the query: main trails, side trails and then remarks.
+func (q *crumbsQuery) Run(groups *GroupedCodeCrumbs) QueryResults {
+	results := QueryResults{}
+	add := func(kind string, crumbs []*parser.CodeCrumb) {
+		for _, cc := range crumbs {
+			if q.Match(kind, cc) {
+				results = append(results, QueryResult{
+					Kind:      kind,
+					CodeCrumb: cc,
+				})
+			}
+		}
+	}
+	for _, trailID := range sortedTrailIDs(groups.MainTrails) {
+		add(TrailKindMain, groups.MainTrails[trailID])
+	}
+	for _, trailID := range sortedTrailIDs(groups.SideTrails) {
+		add(TrailKindSide, groups.SideTrails[trailID])
+	}
+	add(KindRemark, groups.Remarks)
+	return results
+}
+
+func (q *crumbsQuery) Match(kind string, cc *parser.CodeCrumb) bool {
+	var filters []bool
+	if len(q.trails) > 0 {
+		filters = append(filters, matchAnyString(q.trails, func(glob string) bool {
+			ok, _ := path.Match(glob, cc.TrailID)
+			return ok && len(cc.TrailID) > 0
+		}))
+	}
+	if len(q.paths) > 0 {
+		filters = append(filters, matchAnyString(q.paths, func(prefix string) bool {
+			return strings.HasPrefix(strings.TrimPrefix(cc.SourcePath, "/"), strings.TrimPrefix(prefix, "/"))
+		}))
+	}
+	if len(q.langs) > 0 {
+		filters = append(filters, containsFold(q.langs, cc.LanguageName))
+	}
+	if len(q.texts) > 0 {
+		text := cc.Title + "\n" + strings.Join(cc.DescLines, "\n")
+		filters = append(filters, isMatching(text, q.texts))
+	}
+	if len(q.kinds) > 0 {
+		filters = append(filters, containsFold(q.kinds, kind))
+	}
+	if len(filters) == 0 {
+		return true
+	}
+	for _, ok := range filters {
+		if ok && q.matchAny {
+			return true
+		} else if !ok && !q.matchAny {
+			return false
+		}
+	}
+	return !q.matchAny
+}
+
+func matchAnyString(values []string, match func(string) bool) bool {
+	for _, v := range values {
+		if match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines formats results as file:line: title, the format understood by editors.
+func (results QueryResults) Lines(projectDir string) []byte {
+	buf := new(bytes.Buffer)
+	for _, r := range results {
+		fmt.Fprintf(buf, "%s:%d: %s\n", filepath.Join(projectDir, r.SourcePath), r.SourceLine, crumbName(r.CodeCrumb))
+	}
+	return buf.Bytes()
+}
+
+func (results QueryResults) Table() []byte {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "KIND\tTRAIL\tSTEP\tLOCATION\tLANGUAGE\tTITLE")
+	for _, r := range results {
+		trail, step := "-", "-"
+		if len(r.TrailID) > 0 {
+			trail, step = r.TrailID, fmt.Sprint(r.TrailStep)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", r.Kind, trail, step, crumbLocation(r.CodeCrumb), r.LanguageName, r.Title)
+	}
+	w.Flush()
+	return buf.Bytes()
+}
@@ -0,0 +1,449 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"go/build"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+// projectScanner walks the project directory and collects crumbs from the source files. It keeps
+// results between the scans, so only files that have been changed are parsed again.
+type projectScanner struct {
+	dir string
+	scanOptions
+
+	files map[string]*scannedFile
+	order []string
+	// modules maps directories containing go.mod files to module paths
+	modules map[string]string
+}
+
+type scanOptions struct {
+	includes  []string
+	excludes  []*regexp.Regexp
+	syntax    parser.Syntax
+	languages []string
+
+	skipGenerated bool
+	skipTests     bool
+	maxSize       int64
+	// buildContext filters Go files by build constraints, if set
+	buildContext *build.Context
+}
+
+type scannedFile struct {
+	modTime time.Time
+	size    int64
+	crumbs  []*parser.CodeCrumb
+	err     error
+	// skipped files are generated, excluded by build constraints or binary
+	skipped bool
+}
+
+func newProjectScanner(dir string, opts scanOptions) *projectScanner {
+	return &projectScanner{
+		dir:         dir,
+		scanOptions: opts,
+
+		files:   make(map[string]*scannedFile),
+		modules: make(map[string]string),
+	}
+}
+
+// Scan walks the project and returns crumbs grouped by source file, in the walk order. It also reports
+// whether the crumbs or parse errors differ from the previous scan; other changes are ignored.
+func (s *projectScanner) Scan() ([][]*parser.CodeCrumb, bool, error) {
+	var changed bool
+	seen := make(map[string]bool, len(s.files))
+	s.order = s.order[:0]
+	modules := make(map[string]string, len(s.modules))
+	err := filepath.Walk(s.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath := strings.TrimPrefix(path, s.dir)
+		if info.IsDir() {
+			if isMatching(relativePath, s.excludes) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.Name() == goModFile {
+			if data, err := ioutil.ReadFile(path); err == nil {
+				modules[strings.TrimSuffix(relativePath, goModFile)] = parseModulePath(data)
+			}
+			return nil
+		}
+		var head []byte
+		readHead := func() []byte {
+			if head == nil {
+				head = readFileHead(path)
+			}
+			return head
+		}
+		commentLineLang, ok := s.accept(relativePath, readHead)
+		if !ok {
+			return nil
+		} else if s.maxSize > 0 && info.Size() > s.maxSize {
+			log.WithField("file", relativePath).Debugln("skipping file exceeding the size limit")
+			return nil
+		}
+		seen[relativePath] = true
+		s.order = append(s.order, relativePath)
+		prev, ok := s.files[relativePath]
+		if ok && prev.modTime.Equal(info.ModTime()) && prev.size == info.Size() {
+			return nil
+		}
+		file := &scannedFile{
+			modTime: info.ModTime(),
+			size:    info.Size(),
+		}
+		if reason, skip := s.skipContent(relativePath, readHead(), func() (io.ReadCloser, error) {
+			return os.Open(path)
+		}); skip {
+			// the file is kept without crumbs, so it's not parsed again until changed
+			log.WithField("file", relativePath).Debugln("skipping", reason)
+			file.skipped = true
+		} else {
+			file.crumbs, file.err = collectFileCrumbs(path, relativePath, commentLineLang, s.syntax)
+		}
+		if file.err == parser.ErrBinaryFile {
+			log.WithField("file", relativePath).Debugln("skipping binary file")
+			file.err = nil
+			file.skipped = true
+		}
+		if prev != nil && sameCrumbs(prev.crumbs, file.crumbs) {
+			// keep previous crumbs with their IDs
+			file.crumbs = prev.crumbs
+		} else if prev != nil || len(file.crumbs) > 0 {
+			changed = true
+		}
+		if (prev == nil && file.err != nil) || (prev != nil && errorText(prev.err) != errorText(file.err)) {
+			// a new parse error must be reported, even in a file without crumbs
+			changed = true
+		}
+		s.files[relativePath] = file
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	for path, file := range s.files {
+		if !seen[path] {
+			if len(file.crumbs) > 0 || file.err != nil {
+				changed = true
+			}
+			delete(s.files, path)
+		}
+	}
+	if !reflect.DeepEqual(modules, s.modules) {
+		changed = true
+		s.modules = modules
+	}
+	crumbsList := s.crumbsList()
+	tagModules(crumbsList, s.modules)
+	return crumbsList, changed, nil
+}
+
+// accept checks whether the file should be scanned and returns its language. The language is detected
+// by the file name, extensionless files are recognized by the shebang line in the head of the file.
+func (s *projectScanner) accept(relativePath string, head func() []byte) (*parser.LanguageDefinition, bool) {
+	if isMatching(relativePath, s.excludes) {
+		return nil, false
+	}
+	if len(s.includes) > 0 && !containsPrefix(relativePath, s.includes) {
+		return nil, false
+	}
+	if s.skipTests && testFileRx.MatchString(filepath.ToSlash(relativePath)) {
+		return nil, false
+	}
+	lang, ok := parser.LanguageForFile(filepath.Base(relativePath))
+	if !ok && len(filepath.Ext(relativePath)) == 0 {
+		lang, ok = parser.LanguageForShebang(head())
+	}
+	if !ok || (len(s.languages) > 0 && !containsFold(s.languages, lang.Name)) {
+		return nil, false
+	}
+	return lang, true
+}
+
+// ScanRevision collects crumbs from the project files as of the given git revision.
+// Results are not cached, so it doesn't interfere with the regular scans.
+func (s *projectScanner) ScanRevision(rev string) ([][]*parser.CodeCrumb, error) {
+	prefix, err := runGit(s.dir, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	// git archive is limited to the current directory, that isn't a part of the prefix subtree
+	cdup, err := runGit(s.dir, "rev-parse", "--show-cdup")
+	if err != nil {
+		return nil, err
+	}
+	topDir := filepath.Join(s.dir, strings.TrimSpace(string(cdup)))
+	archive, err := runGit(topDir, "archive", "--format=tar", rev+":"+strings.TrimSpace(string(prefix)))
+	if err != nil {
+		return nil, err
+	}
+	var crumbsList [][]*parser.CodeCrumb
+	modules := make(map[string]string)
+	r := tar.NewReader(bytes.NewReader(archive))
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		relativePath := strings.TrimPrefix(filepath.Join(s.dir, filepath.FromSlash(hdr.Name)), s.dir)
+		if s.excludedDir(relativePath) {
+			continue
+		}
+		if filepath.Base(relativePath) == goModFile {
+			if data, err := ioutil.ReadAll(r); err == nil {
+				modules[strings.TrimSuffix(relativePath, goModFile)] = parseModulePath(data)
+			}
+			continue
+		}
+		if s.maxSize > 0 && hdr.Size > s.maxSize {
+			continue
+		}
+		// only the head is read until the file is accepted, the rest of entry is skipped by Next
+		br := bufio.NewReaderSize(r, fileHeadSize)
+		var head []byte
+		readHead := func() []byte {
+			if head == nil {
+				peeked, _ := br.Peek(fileHeadSize)
+				head = append([]byte{}, peeked...)
+			}
+			return head
+		}
+		commentLineLang, ok := s.accept(relativePath, readHead)
+		if !ok {
+			continue
+		}
+		data, err := ioutil.ReadAll(br)
+		if err != nil {
+			return nil, err
+		}
+		if head = data; len(head) > fileHeadSize {
+			head = head[:fileHeadSize]
+		}
+		if _, skip := s.skipContent(relativePath, head, func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}); skip {
+			continue
+		}
+		crumbs, err := parser.CollectCrumbs(relativePath, commentLineLang, s.syntax, bytes.NewReader(data))
+		if err == parser.ErrBinaryFile {
+			continue
+		} else if err != nil {
+			log.WithFields(log.Fields{
+				"file": relativePath,
+				"rev":  rev,
+			}).Warningln(err)
+			continue
+		} else if len(crumbs) > 0 {
+			crumbsList = append(crumbsList, crumbs)
+		}
+	}
+	tagModules(crumbsList, modules)
+	return crumbsList, nil
+}
+
+var (
+	// generatedRx matches the standard header of generated files, see https://golang.org/s/generatedcode
+	generatedRx = regexp.MustCompile(`(?m)^\s*(?://|#|--|/\*|<!--)\s*Code generated .* DO NOT EDIT\.`)
+	// testFileRx matches test files of the common languages and frameworks
+	testFileRx = regexp.MustCompile(`(_test\.go|[._](test|spec)\.[cm]?[jt]sx?|(^|/)test_[^/]*\.py|_test\.py|_(spec|test)\.rb|Tests?\.(java|kt)|(^|/)__tests__/.*)$`)
+)
+
+// skipContent checks whether the file should be skipped by its contents: generated files and Go
+// files excluded by build constraints. It returns the reason of skipping.
+func (s *projectScanner) skipContent(relativePath string, head []byte, open func() (io.ReadCloser, error)) (string, bool) {
+	if s.skipGenerated && generatedRx.Match(head) {
+		return "generated file", true
+	}
+	if s.buildContext != nil && filepath.Ext(relativePath) == ".go" {
+		ctx := *s.buildContext
+		ctx.OpenFile = func(string) (io.ReadCloser, error) {
+			return open()
+		}
+		dir, name := filepath.Split(relativePath)
+		match, err := ctx.MatchFile(dir, name)
+		if err != nil {
+			log.WithField("file", relativePath).Warningln("failed to evaluate build constraints:", err)
+		} else if !match {
+			return "file excluded by build constraints", true
+		}
+	}
+	return "", false
+}
+
+// excludedDir checks whether any of parent directories of the file is excluded.
+func (s *projectScanner) excludedDir(relativePath string) bool {
+	for dir := filepath.Dir(relativePath); dir != "." && dir != string(filepath.Separator); dir = filepath.Dir(dir) {
+		if isMatching(dir, s.excludes) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *projectScanner) crumbsList() [][]*parser.CodeCrumb {
+	crumbsList := make([][]*parser.CodeCrumb, 0, len(s.order))
+	for _, path := range s.order {
+		if crumbs := s.files[path].crumbs; len(crumbs) > 0 {
+			crumbsList = append(crumbsList, crumbs)
+		}
+	}
+	return crumbsList
+}
+
+// Files returns relative paths of the source files found by the last scan, in the walk order.
+// Skipped files are omitted.
+func (s *projectScanner) Files() []string {
+	files := make([]string, 0, len(s.order))
+	for _, path := range s.order {
+		if !s.files[path].skipped {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// LogDiagnostics prints problems found while parsing the source files.
+func (s *projectScanner) LogDiagnostics() {
+	paths := make([]string, 0, len(s.files))
+	for path, file := range s.files {
+		if file.err != nil {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		log.WithFields(log.Fields{
+			"file": path,
+		}).Warningln(s.files[path].err)
+	}
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Problems returns errors of parsing the source files, ordered by path.
+func (s *projectScanner) Problems() []crumbProblem {
+	var problems []crumbProblem
+	for path, file := range s.files {
+		if file.err != nil {
+			problems = append(problems, newParseProblem(path, file.err))
+		}
+	}
+	sort.Slice(problems, func(i, j int) bool {
+		return problems[i].Path < problems[j].Path
+	})
+	return problems
+}
+
+// fileHeadSize is enough to read the shebang line and the header of generated files.
+const fileHeadSize = 8000
+
+func readFileHead(path string) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	head := make([]byte, fileHeadSize)
+	n, _ := io.ReadFull(f, head)
+	return head[:n]
+}
+
+const goModFile = "go.mod"
+
+var modulePathRx = regexp.MustCompile(`(?m)^\s*module\s+"?([^"\s]+)"?`)
+
+func parseModulePath(data []byte) string {
+	if m := modulePathRx.FindSubmatch(data); m != nil {
+		return string(m[1])
+	}
+	return ""
+}
+
+// tagModules sets the module of each crumb, found by the closest directory with go.mod.
+func tagModules(crumbsList [][]*parser.CodeCrumb, modules map[string]string) {
+	for _, crumbs := range crumbsList {
+		_, module := moduleOf(modules, crumbs[0].SourcePath)
+		for _, cc := range crumbs {
+			cc.Module = module
+		}
+	}
+}
+
+// moduleOf returns the directory and path of the module containing the file, i.e. of the closest
+// directory with go.mod, modules are keyed by their directories.
+func moduleOf(modules map[string]string, relativePath string) (string, string) {
+	var moduleDir, module string
+	for dir, modulePath := range modules {
+		if strings.HasPrefix(relativePath, dir) && len(dir) >= len(moduleDir) {
+			moduleDir, module = dir, modulePath
+		}
+	}
+	return moduleDir, module
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func collectFileCrumbs(path, relativePath string, lang *parser.LanguageDefinition, syntax parser.Syntax) ([]*parser.CodeCrumb, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parser.CollectCrumbs(relativePath, lang, syntax, f)
+}
+
+// sameCrumbs compares two lists of crumbs ignoring their module tags, which are assigned later,
+// and IDs, which follow from the path and line that are compared anyway.
+func sameCrumbs(a, b []*parser.CodeCrumb) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		ac, bc := *a[i], *b[i]
+		ac.ID, bc.ID = "", ""
+		ac.Module, bc.Module = "", ""
+		if !reflect.DeepEqual(ac, bc) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/renderer"
+)
+
+func cmdServe(c *cli.Cmd) {
+	listenAddr := c.StringOpt("a addr", "localhost:8080", "Address to listen on for HTTP connections.")
+	c.Action = func() {
+		useConfig()
+		srv := &previewServer{
+			scanner: newScannerFromOptions(),
+			clients: make(map[chan struct{}]struct{}),
+		}
+		if err := srv.update(true); err != nil {
+			log.Fatalln(err)
+		}
+		go func() {
+			for range time.Tick(watchIntervalFromOptions()) {
+				if err := srv.update(false); err != nil {
+					log.Errorln(err)
+				}
+			}
+		}()
+		log.Infof("serving live preview on http://%s", *listenAddr)
+		if err := http.ListenAndServe(*listenAddr, srv); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// previewServer serves the documentation rendered into HTML, connected browsers
+// are notified to reload the page via server-sent events.
+type previewServer struct {
+	scanner *projectScanner
+
+	mux     sync.RWMutex
+	page    []byte
+	sources map[string]bool
+	clients map[chan struct{}]struct{}
+}
+
+// sourceRoute serves the source files of crumbs, relative source links of the page point there.
+const sourceRoute = "/source/"
+
+// update rescans the project and re-renders the page if crumbs have been changed.
+func (s *previewServer) update(force bool) error {
+	crumbsList, changed, err := s.scanner.Scan()
+	if err != nil {
+		return err
+	} else if !changed && !force {
+		return nil
+	}
+	s.scanner.LogDiagnostics()
+	g, err := newMarkdownGenerator(outputOptionsFromFlags())
+	if err != nil {
+		return err
+	}
+	g.LinkBase = sourceRoute
+	sources := make(map[string]bool)
+	for _, crumbs := range crumbsList {
+		sources[strings.TrimPrefix(filepath.ToSlash(crumbs[0].SourcePath), "/")] = true
+	}
+	groups := regroupCodeCrumbs(*projectEntry, crumbsList)
+	doc, err := g.RenderDocument(groups.MainTrails, groups.SideTrails, groups.Remarks)
+	if err != nil {
+		return err
+	}
+	page, err := renderer.NewLocalRenderer(*projectName).RenderHTML(doc)
+	if err != nil {
+		return err
+	}
+	page = bytes.Replace(page, []byte("</body>"), []byte(reloadScript+"</body>"), 1)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.page = page
+	s.sources = sources
+	for ch := range s.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+	if !force {
+		log.Infoln("preview has been re-rendered")
+	}
+	return nil
+}
+
+const reloadScript = `<script>
+new EventSource("/events").onmessage = function() { window.location.reload(); };
+</script>`
+
+func (s *previewServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/":
+		s.mux.RLock()
+		page := s.page
+		s.mux.RUnlock()
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	case "/events":
+		s.serveEvents(w, r)
+	default:
+		if strings.HasPrefix(r.URL.Path, sourceRoute) {
+			s.serveSource(w, r, strings.TrimPrefix(r.URL.Path, sourceRoute))
+			return
+		}
+		http.NotFound(w, r)
+	}
+}
+
+// serveSource shows the source file with crumbs as numbered lines, so #L anchors of source
+// links work. Other files of the project are not served.
+func (s *previewServer) serveSource(w http.ResponseWriter, r *http.Request, relativePath string) {
+	s.mux.RLock()
+	ok := s.sources[relativePath]
+	s.mux.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	data, err := ioutil.ReadFile(filepath.Join(*projectDir, filepath.FromSlash(relativePath)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>%s</title></head><body><pre>",
+		html.EscapeString(relativePath))
+	for i, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(buf, "<span id=\"L%d\">%5d  %s</span>\n", i+1, i+1, html.EscapeString(line))
+	}
+	fmt.Fprint(buf, "</pre></body></html>")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (s *previewServer) serveEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ch := make(chan struct{}, 1)
+	s.mux.Lock()
+	s.clients[ch] = struct{}{}
+	s.mux.Unlock()
+	defer func() {
+		s.mux.Lock()
+		delete(s.clients, ch)
+		s.mux.Unlock()
+	}()
+
+	keepAlive := time.NewTicker(15 * time.Second)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-ch:
+			fmt.Fprint(w, "data: reload\n\n")
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	goparser "go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/generator"
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	StatsFormatTable    = "table"
+	StatsFormatJSON     = "json"
+	StatsFormatMarkdown = "markdown"
+)
+
+func cmdStats(c *cli.Cmd) {
+	statsFormat := c.StringOpt("f format", StatsFormatTable, "The format of the report. Available: table, json, markdown.")
+	depth := c.IntOpt("depth", 0, "Aggregate directories up to the depth, e.g. 1 for top-level directories. Every directory is reported by default.")
+	nearby := c.IntOpt("nearby", 3, "An exported Go identifier is documented if a crumb is placed within its declaration, doc comment or that many lines above.")
+	appendOutput := c.BoolOpt("append", false, "Append the report to the output file instead of overwriting it, e.g. to the generated document.")
+	skipDirs := c.StringsOpt("skip-dirs", []string{"vendor", "testdata"}, "Names of directories left out of the report along with their contents, e.g. vendored dependencies.")
+	c.Action = func() {
+		useConfig()
+		switch *statsFormat {
+		case StatsFormatTable, StatsFormatJSON, StatsFormatMarkdown:
+		default:
+			log.Fatalln("unsupported stats format:", *statsFormat)
+		}
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scanner.LogDiagnostics()
+		report := newCoverageReport(scanner, crumbsList, *skipDirs, *depth, *nearby)
+
+		var buf []byte
+		switch *statsFormat {
+		case StatsFormatTable:
+			buf = coverageTable(report)
+		case StatsFormatJSON:
+			buf, err = json.MarshalIndent(report, "", "\t")
+			if err != nil {
+				log.Fatalln(err)
+			}
+			buf = append(buf, '\n')
+		case StatsFormatMarkdown:
+			g, err := newMarkdownGenerator(outputOptionsFromFlags())
+			if err != nil {
+				log.Fatalln(err)
+			}
+			if buf, err = g.RenderCoverage(report); err != nil {
+				log.Fatalln(err)
+			}
+		}
+		if len(*outputFile) == 0 {
+			fmt.Print(string(buf))
+			return
+		}
+		flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+		if *appendOutput {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+			buf = append([]byte("\n"), buf...)
+		}
+		f, err := os.OpenFile(*outputFile, flags, 0600)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if _, err := f.Write(buf); err != nil {
+			f.Close()
+			log.Fatalln(err)
+		}
+		if err := f.Close(); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// newCoverageReport aggregates crumbs, lines of code and exported Go identifiers of the scanned
+// files by directory. With depth > 0, nested directories are counted in their parent at the depth.
+// Files in directories named as one of skipDirs, e.g. vendor, are not counted.
+func newCoverageReport(s *projectScanner, crumbsList [][]*parser.CodeCrumb, skipDirs []string,
+	depth, nearby int) *generator.CoverageReport {
+	type dirStats struct {
+		generator.PackageCoverage
+
+		trails map[string]bool
+	}
+	dirs := make(map[string]*dirStats)
+	dirOf := func(relativePath string) *dirStats {
+		dir := coverageDir(relativePath, depth)
+		stats, ok := dirs[dir]
+		if !ok {
+			stats = &dirStats{
+				PackageCoverage: generator.PackageCoverage{
+					Path: dir,
+				},
+				trails: make(map[string]bool),
+			}
+			dirs[dir] = stats
+		}
+		return stats
+	}
+	total := &dirStats{
+		trails: make(map[string]bool),
+	}
+
+	crumbLines := make(map[string][]int)
+	for _, crumbs := range crumbsList {
+		if inSkippedDir(crumbs[0].SourcePath, skipDirs) {
+			continue
+		}
+		for _, cc := range crumbs {
+			for _, stats := range []*dirStats{dirOf(cc.SourcePath), total} {
+				stats.Crumbs++
+				if len(cc.TrailID) == 0 {
+					stats.Remarks++
+				} else {
+					stats.trails[cc.TrailID] = true
+				}
+			}
+			crumbLines[cc.SourcePath] = append(crumbLines[cc.SourcePath], cc.SourceLine)
+		}
+	}
+	for _, relativePath := range s.Files() {
+		if inSkippedDir(relativePath, skipDirs) {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(s.dir, relativePath))
+		if err != nil {
+			log.WithField("file", relativePath).Warningln(err)
+			continue
+		}
+		stats := dirOf(relativePath)
+		lines := countLines(data)
+		for _, stats := range []*dirStats{stats, total} {
+			stats.Files++
+			stats.Lines += lines
+		}
+		if filepath.Ext(relativePath) != ".go" || strings.HasSuffix(relativePath, "_test.go") {
+			continue
+		}
+		if len(stats.Package) == 0 && depth == 0 {
+			stats.Package = goImportPath(s.modules, relativePath)
+		}
+		idents, err := exportedIdents(relativePath, data)
+		if err != nil {
+			log.WithField("file", relativePath).Debugln("failed to parse Go file:", err)
+			continue
+		}
+		for _, ident := range idents {
+			stats.Exported++
+			total.Exported++
+			if !crumbNearby(crumbLines[relativePath], ident, nearby) {
+				stats.Undocumented++
+				total.Undocumented++
+				stats.UndocumentedIdents = append(stats.UndocumentedIdents, ident.Identifier)
+			}
+		}
+	}
+
+	report := &generator.CoverageReport{}
+	finish := func(stats *dirStats) generator.PackageCoverage {
+		stats.Trails = len(stats.trails)
+		if stats.Lines > 0 {
+			stats.CrumbsPerKLOC = float64(stats.Crumbs) * 1000 / float64(stats.Lines)
+		}
+		return stats.PackageCoverage
+	}
+	for _, stats := range dirs {
+		report.Packages = append(report.Packages, finish(stats))
+	}
+	sort.Slice(report.Packages, func(i, j int) bool {
+		return report.Packages[i].Path < report.Packages[j].Path
+	})
+	report.Total = finish(total)
+	report.Total.Path = "."
+	return report
+}
+
+// coverageDir returns the slash-separated directory of the file, cut to the depth.
+func coverageDir(relativePath string, depth int) string {
+	dir := path.Dir(strings.TrimPrefix(filepath.ToSlash(relativePath), "/"))
+	if depth > 0 && dir != "." {
+		if parts := strings.Split(dir, "/"); len(parts) > depth {
+			dir = strings.Join(parts[:depth], "/")
+		}
+	}
+	return dir
+}
+
+// inSkippedDir checks whether any of the parent directories of the file has one of the names.
+func inSkippedDir(relativePath string, names []string) bool {
+	parts := strings.Split(filepath.ToSlash(relativePath), "/")
+	for _, dir := range parts[:len(parts)-1] {
+		for _, name := range names {
+			if dir == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// countLines counts non-blank lines.
+func countLines(data []byte) int {
+	var n int
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// goImportPath returns the import path of the package containing the Go file,
+// found by the closest directory with go.mod.
+func goImportPath(modules map[string]string, relativePath string) string {
+	moduleDir, module := moduleOf(modules, relativePath)
+	if len(module) == 0 {
+		return ""
+	}
+	dir := filepath.ToSlash(filepath.Dir(strings.TrimPrefix(relativePath, moduleDir)))
+	if dir == "." {
+		return module
+	}
+	return module + "/" + dir
+}
+
+// declIdent is an exported identifier along with the lines its declaration spans,
+// including the doc comment.
+type declIdent struct {
+	generator.Identifier
+
+	fromLine int
+	toLine   int
+}
+
+// exportedIdents finds exported top-level functions, methods of exported types, types,
+// constants and variables of the Go file. Commands, i.e. main packages, export nothing.
+func exportedIdents(relativePath string, data []byte) ([]declIdent, error) {
+	fset := token.NewFileSet()
+	f, err := goparser.ParseFile(fset, relativePath, data, goparser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	var idents []declIdent
+	if f.Name.Name == "main" {
+		return idents, nil
+	}
+	add := func(name *ast.Ident, doc *ast.CommentGroup, node ast.Node) {
+		from := node.Pos()
+		if doc != nil {
+			from = doc.Pos()
+		}
+		idents = append(idents, declIdent{
+			Identifier: generator.Identifier{
+				Name: name.Name,
+				Path: relativePath,
+				Line: fset.Position(name.Pos()).Line,
+			},
+			fromLine: fset.Position(from).Line,
+			toLine:   fset.Position(node.End()).Line,
+		})
+	}
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if !decl.Name.IsExported() {
+				continue
+			}
+			if decl.Recv != nil && len(decl.Recv.List) > 0 {
+				if recv := receiverType(decl.Recv.List[0].Type); recv == nil || !recv.IsExported() {
+					continue
+				}
+			}
+			add(decl.Name, decl.Doc, decl)
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				// specs of a single declaration are documented by the declaration comment
+				doc, node := decl.Doc, ast.Node(decl)
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					if decl.Lparen.IsValid() {
+						doc, node = spec.Doc, spec
+					}
+					if spec.Name.IsExported() {
+						add(spec.Name, doc, node)
+					}
+				case *ast.ValueSpec:
+					if decl.Lparen.IsValid() {
+						doc, node = spec.Doc, spec
+					}
+					for _, name := range spec.Names {
+						if name.IsExported() {
+							add(name, doc, node)
+						}
+					}
+				}
+			}
+		}
+	}
+	return idents, nil
+}
+
+func receiverType(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return receiverType(t.X)
+	case *ast.IndexExpr:
+		// generic receivers, e.g. List[T]
+		return receiverType(t.X)
+	}
+	return nil
+}
+
+// crumbNearby checks whether any of the crumb lines is within the declaration of the identifier,
+// its doc comment or the given number of lines above.
+func crumbNearby(lines []int, ident declIdent, nearby int) bool {
+	for _, line := range lines {
+		if line >= ident.fromLine-nearby && line <= ident.toLine {
+			return true
+		}
+	}
+	return false
+}
+
+func coverageTable(report *generator.CoverageReport) []byte {
+	buf := new(bytes.Buffer)
+	w := tabwriter.NewWriter(buf, 0, 4, 2, ' ', 0)
+	row := func(name string, p generator.PackageCoverage) {
+		undocumented := "-"
+		if p.Exported > 0 {
+			undocumented = fmt.Sprintf("%d/%d", p.Undocumented, p.Exported)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t%.1f\t%s\n",
+			name, p.Files, p.Lines, p.Crumbs, p.Trails, p.Remarks, p.CrumbsPerKLOC, undocumented)
+	}
+	fmt.Fprintln(w, "DIRECTORY\tFILES\tLINES\tCRUMBS\tTRAILS\tREMARKS\tPER KLOC\tUNDOCUMENTED")
+	for _, p := range report.Packages {
+		row(p.Path, p)
+	}
+	row("TOTAL", report.Total)
+	w.Flush()
+	return buf.Bytes()
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/AtlantPlatform/codecrumbs-go/generator"
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+// QuickfixRemarksFile is the name of the quickfix list of remarks in the split output.
+const QuickfixRemarksFile = "remarks"
+
+// ctagsFile renders crumbs as a sorted tags file in the extended ctags format, understood by vim,
+// emacs and most of other editors. Paths are relative to the directory of the tags file.
+func ctagsFile(groups *GroupedCodeCrumbs, tagsDir string) []byte {
+	var tags []string
+	for _, r := range (&crumbsQuery{}).Run(groups) {
+		kind := "kind:step\ttrail:" + r.TrailID
+		if r.Kind == KindRemark {
+			kind = "kind:remark"
+		}
+		tags = append(tags, fmt.Sprintf("%s\t%s\t%d;\"\t%s",
+			crumbTag(r.CodeCrumb), filepath.ToSlash(editorPath(r.SourcePath, tagsDir)), r.SourceLine, kind))
+	}
+	sort.Strings(tags)
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintln(buf, "!_TAG_FILE_FORMAT\t2\t/extended format/")
+	fmt.Fprintln(buf, "!_TAG_FILE_SORTED\t1\t/0=unsorted, 1=sorted, 2=foldcase/")
+	fmt.Fprintln(buf, "!_TAG_PROGRAM_NAME\tcc-go\t//")
+	for _, tag := range tags {
+		fmt.Fprintln(buf, tag)
+	}
+	return buf.Bytes()
+}
+
+// crumbTag names the tag of the crumb after its trail, step and title, e.g. auth#2-check-token.
+func crumbTag(cc *parser.CodeCrumb) string {
+	prefix := "remark"
+	if len(cc.TrailID) > 0 {
+		prefix = fmt.Sprintf("%s#%d", strings.Replace(cc.TrailID, " ", "-", -1), cc.TrailStep)
+	}
+	if slug := tagSlug(cc.Title); len(slug) > 0 {
+		return prefix + "-" + slug
+	}
+	return prefix
+}
+
+func tagSlug(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, "-")
+}
+
+// quickfixLists renders crumbs in the file:line:col: message format of vim quickfix and emacs
+// compilation mode, so the editor walks a trail step by step. Lists are keyed by trail ID,
+// remarks are listed under the empty key.
+func quickfixLists(groups *GroupedCodeCrumbs) (map[string][]byte, []string) {
+	lists := make(map[string]*bytes.Buffer)
+	var order []string
+	for _, r := range (&crumbsQuery{}).Run(groups) {
+		name := r.TrailID
+		if r.Kind == KindRemark {
+			name = ""
+		}
+		buf, ok := lists[name]
+		if !ok {
+			buf = new(bytes.Buffer)
+			lists[name] = buf
+			order = append(order, name)
+		}
+		message := crumbName(r.CodeCrumb)
+		if len(r.DescLines) > 0 && len(r.DescLines[0]) > 0 {
+			message += ": " + r.DescLines[0]
+		}
+		fmt.Fprintf(buf, "%s:%d:1: %s\n", editorPath(r.SourcePath, ""), r.SourceLine, message)
+	}
+	result := make(map[string][]byte, len(lists))
+	for name, buf := range lists {
+		result[name] = buf.Bytes()
+	}
+	return result, order
+}
+
+// quickfixFiles names files of the split quickfix output after the trails, remarks go to
+// QuickfixRemarksFile. Names that are empty or collide with others regardless of the case,
+// e.g. of trails auth and Auth, get a numeric suffix.
+func quickfixFiles(order []string) map[string]string {
+	files := make(map[string]string, len(order))
+	names := make(generator.FileNames)
+	for _, key := range order {
+		if len(key) == 0 {
+			files[key] = names.Take(QuickfixRemarksFile, "") + ".qf"
+		}
+	}
+	for _, key := range order {
+		if len(key) > 0 {
+			files[key] = names.Take(tagSlug(key), "trail") + ".qf"
+		}
+	}
+	return files
+}
+
+// editorPath returns the path of the source file relative to the base directory,
+// or to the working directory if base is empty.
+func editorPath(sourcePath, base string) string {
+	path := filepath.Join(*projectDir, sourcePath)
+	if len(base) == 0 {
+		return path
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	absBase, err := filepath.Abs(base)
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(absBase, absPath); err == nil {
+		return rel
+	}
+	return absPath
+}
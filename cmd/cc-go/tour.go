@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	cli "github.com/jawher/mow.cli"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+func cmdTour(c *cli.Cmd) {
+	c.Spec = "[OPTIONS] TRAIL"
+	trailID := c.StringArg("TRAIL", "", "ID of the trail to walk through.")
+	contextLines := c.IntOpt("C context", 5, "Number of source lines to show around each crumb.")
+	noColor := c.BoolOpt("no-color", false, "Disable colours, also disabled by NO_COLOR or when the output is not a terminal.")
+	c.Action = func() {
+		useConfig()
+		scanner := newScannerFromOptions()
+		crumbsList, _, err := scanner.Scan()
+		if err != nil {
+			log.Fatalln(err)
+		}
+		scanner.LogDiagnostics()
+		groups := regroupCodeCrumbs(*projectEntry, crumbsList)
+		trail, ok := groups.MainTrails[*trailID]
+		if !ok {
+			trail, ok = groups.SideTrails[*trailID]
+		}
+		if !ok {
+			ids := append(sortedTrailIDs(groups.MainTrails), sortedTrailIDs(groups.SideTrails)...)
+			log.Fatalf("trail not found: %s, available trails: %s", *trailID, strings.Join(ids, ", "))
+		}
+		t := &trailTour{
+			dir:     *projectDir,
+			trailID: *trailID,
+			crumbs:  trail,
+			context: *contextLines,
+			color:   !*noColor && useColor(os.Stdout),
+			out:     os.Stdout,
+		}
+		if err := t.Run(os.Stdin); err != nil {
+			log.Fatalln(err)
+		}
+	}
+}
+
+// useColor reports whether ANSI colours can be written to the file.
+func useColor(f *os.File) bool {
+	if len(os.Getenv("NO_COLOR")) > 0 || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// trailTour walks through the crumbs of a trail in the terminal, reading commands line by line.
+type trailTour struct {
+	dir     string
+	trailID string
+	crumbs  []*parser.CodeCrumb
+	context int
+	color   bool
+	out     io.Writer
+}
+
+// tourHelp is shown before each prompt, commands are read by lines, so each of them ends with Enter.
+const tourHelp = "[enter/n] next  [p] previous  [NUM] go to step  [e] open in editor  [q] quit  (confirm with enter)"
+
+func (t *trailTour) Run(in io.Reader) error {
+	input := bufio.NewReader(in)
+	pos := 0
+	show := true
+	for {
+		if show {
+			t.show(pos)
+		}
+		show = true
+		fmt.Fprint(t.out, t.style(ansiMarker, tourHelp), "\n> ")
+		cmd, err := input.ReadString('\n')
+		if err == io.EOF && len(cmd) == 0 {
+			fmt.Fprintln(t.out)
+			return nil
+		} else if err != nil && err != io.EOF {
+			return err
+		}
+		switch cmd = strings.ToLower(strings.TrimSpace(cmd)); cmd {
+		case "", "n", "next":
+			if pos == len(t.crumbs)-1 {
+				fmt.Fprintln(t.out, "End of the trail.")
+				return nil
+			}
+			pos++
+		case "p", "prev", "previous":
+			if pos == 0 {
+				fmt.Fprintln(t.out, "This is the first step.")
+				show = false
+				continue
+			}
+			pos--
+		case "e", "edit":
+			if err := t.edit(t.crumbs[pos]); err != nil {
+				log.Errorln(err)
+			}
+			show = false
+		case "q", "quit", "exit":
+			return nil
+		default:
+			step, err := strconv.Atoi(cmd)
+			if err != nil || step < 1 || step > len(t.crumbs) {
+				fmt.Fprintf(t.out, "Unknown command %q, the trail has %d steps.\n", cmd, len(t.crumbs))
+				show = false
+				continue
+			}
+			pos = step - 1
+		}
+	}
+}
+
+func (t *trailTour) style(style, text string) string {
+	if !t.color {
+		return text
+	}
+	return style + text + ansiReset
+}
+
+func (t *trailTour) show(pos int) {
+	cc := t.crumbs[pos]
+	fmt.Fprintln(t.out)
+	fmt.Fprintln(t.out, t.style(ansiMarker, fmt.Sprintf("%s · step %d of %d", t.trailID, pos+1, len(t.crumbs))))
+	fmt.Fprintln(t.out, t.style(ansiBold, cc.Title))
+	for _, line := range cc.DescLines {
+		fmt.Fprintln(t.out, line)
+	}
+	fmt.Fprintln(t.out)
+	fmt.Fprintln(t.out, t.style(ansiComment, crumbLocation(cc)))
+
+	data, err := ioutil.ReadFile(filepath.Join(t.dir, cc.SourcePath))
+	if err != nil {
+		fmt.Fprintln(t.out, "Source is not available:", err)
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.Replace(strings.TrimSuffix(lines[i], "\r"), "\t", "    ", -1)
+	}
+	peekFrom, peekTo := peekedRange(lines, cc)
+	from := cc.SourceLine - t.context
+	if from < 1 {
+		from = 1
+	}
+	to := cc.SourceLine + t.context
+	if peekTo+t.context > to {
+		to = peekTo + t.context
+	}
+	if to > len(lines) {
+		to = len(lines)
+	}
+	width := len(strconv.Itoa(to))
+	h := newHighlighter(cc.LanguageName)
+	// feed the lines before the context, so comments opened above are tracked
+	for n := 1; n < from; n++ {
+		h.Line(lines[n-1], "")
+	}
+	for n := from; n <= to; n++ {
+		marker, base := "  ", ""
+		switch {
+		case n == cc.SourceLine:
+			marker = "> "
+		case n >= peekFrom && n <= peekTo:
+			marker, base = "| ", ansiPeek
+		}
+		gutter := fmt.Sprintf("%s%*d  ", marker, width, n)
+		line := lines[n-1]
+		if t.color {
+			gutter = t.style(ansiMarker, gutter)
+			line = h.Line(line, base)
+		}
+		fmt.Fprintln(t.out, gutter+line)
+	}
+}
+
+// peekedRange finds line numbers of the peeked lines, they follow the comment of the crumb.
+func peekedRange(lines []string, cc *parser.CodeCrumb) (int, int) {
+	if len(cc.PeekedLines) == 0 {
+		return 0, -1
+	}
+	for n := cc.SourceLine + 1; n+len(cc.PeekedLines)-1 <= len(lines); n++ {
+		match := true
+		for i, peeked := range cc.PeekedLines {
+			if strings.TrimRight(lines[n-1+i], " ") != strings.Replace(strings.TrimRight(peeked, " \r"), "\t", "    ", -1) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return n, n + len(cc.PeekedLines) - 1
+		}
+	}
+	return 0, -1
+}
+
+// edit opens the crumb in $VISUAL or $EDITOR at its line.
+func (t *trailTour) edit(cc *parser.CodeCrumb) error {
+	editor := os.Getenv("VISUAL")
+	if len(editor) == 0 {
+		editor = os.Getenv("EDITOR")
+	}
+	if len(editor) == 0 {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+	args := strings.Fields(editor)
+	file := filepath.Join(t.dir, cc.SourcePath)
+	switch name := strings.TrimSuffix(filepath.Base(args[0]), ".exe"); name {
+	case "code", "code-insiders", "codium":
+		args = append(args, "--goto", fmt.Sprintf("%s:%d", file, cc.SourceLine))
+	case "subl", "atom", "zed":
+		args = append(args, fmt.Sprintf("%s:%d", file, cc.SourceLine))
+	case "notepad":
+		args = append(args, file)
+	default:
+		// vi, emacs, nano and most of other editors
+		args = append(args, fmt.Sprintf("+%d", cc.SourceLine), file)
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
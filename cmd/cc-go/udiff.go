@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// unifiedDiff returns a unified diff of two texts with 3 lines of context,
+// or an empty string if the texts are equal.
+func unifiedDiff(oldName, newName string, oldText, newText []byte) string {
+	if bytes.Equal(oldText, newText) {
+		return ""
+	}
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+	const context = 3
+
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "--- %s\n+++ %s\n", oldName, newName)
+	var oldLine, newLine int
+	for i := 0; i < len(lines); {
+		if lines[i].op == diffEqual {
+			oldLine++
+			newLine++
+			i++
+			continue
+		}
+		// found a change, collect the hunk including context around it
+		start := i
+		for start > 0 && i-start < context && lines[start-1].op == diffEqual {
+			start--
+		}
+		end := hunkEnd(lines, i, context)
+
+		oldStart, newStart := oldLine-(i-start)+1, newLine-(i-start)+1
+		var oldCount, newCount int
+		hunk := new(bytes.Buffer)
+		for _, l := range lines[start:end] {
+			switch l.op {
+			case diffEqual:
+				oldCount++
+				newCount++
+				fmt.Fprintf(hunk, " %s\n", l.text)
+			case diffDelete:
+				oldCount++
+				fmt.Fprintf(hunk, "-%s\n", l.text)
+			case diffInsert:
+				newCount++
+				fmt.Fprintf(hunk, "+%s\n", l.text)
+			}
+		}
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(buf, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+		buf.Write(hunk.Bytes())
+		for _, l := range lines[i:end] {
+			if l.op != diffInsert {
+				oldLine++
+			}
+			if l.op != diffDelete {
+				newLine++
+			}
+		}
+		i = end
+	}
+	return buf.String()
+}
+
+// hunkEnd finds the end of the hunk starting with a change at i: changes separated by
+// less than 2*context equal lines are merged into the same hunk.
+func hunkEnd(lines []diffLine, i, context int) int {
+	lastChange := i
+	for j := i; j < len(lines); j++ {
+		if lines[j].op != diffEqual {
+			lastChange = j
+		} else if j-lastChange > 2*context {
+			break
+		}
+	}
+	end := lastChange + 1 + context
+	if end > len(lines) {
+		end = len(lines)
+	}
+	return end
+}
+
+func splitLines(text []byte) []string {
+	s := string(text)
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines computes the shortest edit script using the Myers' algorithm.
+func diffLines(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+2)
+	var trace [][]int
+	for d := 0; d <= max; d++ {
+		vc := make([]int, len(v))
+		copy(vc, v)
+		trace = append(trace, vc)
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrackDiff(a, b, trace, d, offset)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrackDiff(a, b []string, trace [][]int, d, offset int) []diffLine {
+	var lines []diffLine
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{diffEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, diffLine{diffInsert, b[y]})
+		} else {
+			x--
+			lines = append(lines, diffLine{diffDelete, a[x]})
+		}
+	}
+	for x > 0 && y > 0 {
+		x--
+		y--
+		lines = append(lines, diffLine{diffEqual, a[x]})
+	}
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
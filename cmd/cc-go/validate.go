@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	RuleParseError    = "parse-error"
+	RuleInvalidStep   = "invalid-step"
+	RuleDuplicateStep = "duplicate-step"
+	RuleStepGap       = "step-gap"
+	// RuleUnresolvedEntry is reported when the entrypoint doesn't match any file with crumbs.
+	RuleUnresolvedEntry = "unresolved-entry"
+)
+
+type problemRule struct {
+	ID          string
+	Name        string
+	Description string
+	// Level is the SARIF level of the problems: error or warning.
+	Level string
+}
+
+var problemRules = []problemRule{{
+	ID:          RuleParseError,
+	Name:        "ParseError",
+	Description: "The source file cannot be parsed, e.g. a comment block contains multiple markers.",
+	Level:       "error",
+}, {
+	ID:          RuleInvalidStep,
+	Name:        "InvalidStep",
+	Description: "The step of a trail crumb must be a positive number.",
+	Level:       "warning",
+}, {
+	ID:          RuleDuplicateStep,
+	Name:        "DuplicateStep",
+	Description: "Several crumbs of the trail have the same step, so the order of the trail is ambiguous.",
+	Level:       "warning",
+}, {
+	ID:          RuleStepGap,
+	Name:        "StepGap",
+	Description: "Steps of the trail don't go one by one, some steps are missing.",
+	Level:       "warning",
+}, {
+	ID:          RuleUnresolvedEntry,
+	Name:        "UnresolvedEntry",
+	Description: "The project entrypoint doesn't match any file with crumbs, so there are no main trails.",
+	Level:       "warning",
+}}
+
+// crumbProblem is an issue found in the sources: a file that cannot be parsed or a broken trail.
+type crumbProblem struct {
+	Rule    string
+	Message string
+	Path    string
+	// Line is zero if the problem is not bound to a line.
+	Line int
+	// Crumb is the crumb with the problem, nil for parse errors.
+	Crumb *parser.CodeCrumb
+	// Entry is the entrypoint the problem is about, used if the entrypoint file doesn't exist.
+	Entry string
+}
+
+func newParseProblem(path string, err error) crumbProblem {
+	problem := crumbProblem{
+		Rule:    RuleParseError,
+		Message: err.Error(),
+		Path:    path,
+	}
+	if err, ok := err.(*parser.SyntaxError); ok {
+		problem.Message = err.Err.Error()
+		problem.Line = err.Line
+	}
+	return problem
+}
+
+// validateTrails checks that steps of each trail are positive, unique and go without gaps.
+func validateTrails(groups *GroupedCodeCrumbs) []crumbProblem {
+	var problems []crumbProblem
+	check := func(trailID string, trail []*parser.CodeCrumb) {
+		var prev *parser.CodeCrumb
+		for _, cc := range trail {
+			problem := crumbProblem{
+				Path:  cc.SourcePath,
+				Line:  cc.SourceLine,
+				Crumb: cc,
+			}
+			switch {
+			case cc.TrailStep <= 0:
+				problem.Rule = RuleInvalidStep
+				problem.Message = fmt.Sprintf("step of trail %s must be a positive number", trailID)
+			case prev != nil && prev.TrailStep == cc.TrailStep:
+				problem.Rule = RuleDuplicateStep
+				problem.Message = fmt.Sprintf("step %d of trail %s is already defined at %s",
+					cc.TrailStep, trailID, crumbLocation(prev))
+			default:
+				from := 1
+				if prev != nil {
+					from = prev.TrailStep + 1
+				}
+				prev = cc
+				if cc.TrailStep == from {
+					continue
+				}
+				problem.Rule = RuleStepGap
+				if cc.TrailStep-from == 1 {
+					problem.Message = fmt.Sprintf("step %d of trail %s is missing", from, trailID)
+				} else {
+					problem.Message = fmt.Sprintf("steps %d-%d of trail %s are missing", from, cc.TrailStep-1, trailID)
+				}
+			}
+			problems = append(problems, problem)
+		}
+	}
+	for _, trailID := range sortedTrailIDs(groups.MainTrails) {
+		check(trailID, groups.MainTrails[trailID])
+	}
+	for _, trailID := range sortedTrailIDs(groups.SideTrails) {
+		check(trailID, groups.SideTrails[trailID])
+	}
+	return problems
+}
+
+// validateEntry checks that the entrypoint refers to a file with crumbs. The problem is located
+// in the entrypoint file, if it exists in the project directory.
+func validateEntry(dir, entryPoint string, crumbsList [][]*parser.CodeCrumb) []crumbProblem {
+	if len(entryPoint) == 0 {
+		return nil
+	}
+	for _, crumbs := range crumbsList {
+		if strings.HasSuffix(crumbs[0].SourcePath, entryPoint) {
+			return nil
+		}
+	}
+	problem := crumbProblem{
+		Rule:    RuleUnresolvedEntry,
+		Message: fmt.Sprintf("entrypoint %s doesn't match any file with crumbs, all trails are side trails", entryPoint),
+		Entry:   entryPoint,
+	}
+	if info, err := os.Stat(filepath.Join(dir, entryPoint)); err == nil && !info.IsDir() {
+		problem.Path = entryPoint
+	}
+	return []crumbProblem{problem}
+}
@@ -0,0 +1,36 @@
+package generator
+
+// PackageCoverage is the documentation coverage of a directory, which is a package in Go.
+type PackageCoverage struct {
+	Path string `json:"path"`
+	// Package is the import path of the Go package in the directory, if any.
+	Package       string  `json:"package,omitempty"`
+	Files         int     `json:"files"`
+	Lines         int     `json:"lines"`
+	Crumbs        int     `json:"crumbs"`
+	Trails        int     `json:"trails"`
+	Remarks       int     `json:"remarks"`
+	CrumbsPerKLOC float64 `json:"crumbs_per_kloc"`
+	// Exported is the number of exported Go identifiers, Undocumented is the number of them without
+	// crumbs nearby. UndocumentedIdents are listed for packages only, not for the total.
+	Exported           int          `json:"exported"`
+	Undocumented       int          `json:"undocumented"`
+	UndocumentedIdents []Identifier `json:"undocumented_idents,omitempty"`
+}
+
+type Identifier struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Line int    `json:"line"`
+}
+
+// CoverageReport is the documentation coverage of the project by directories.
+type CoverageReport struct {
+	Packages []PackageCoverage `json:"packages"`
+	Total    PackageCoverage   `json:"total"`
+}
+
+// RenderCoverage renders the coverage report as a Markdown section, so it can be appended to the document.
+func (m *Markdown) RenderCoverage(report *CoverageReport) ([]byte, error) {
+	return m.execute("coverage", report)
+}
@@ -0,0 +1,236 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+const (
+	LinkStylePlain       = "plain"
+	LinkStyleGithub      = "github"
+	LinkStyleGitlab      = "gitlab"
+	LinkStyleBitbucket   = "bitbucket"
+	LinkStyleGitea       = "gitea"
+	LinkStyleAzure       = "azure"
+	LinkStyleSourcegraph = "sourcegraph"
+	LinkStyleFile        = "file"
+	LinkStyleVSCode      = "vscode"
+)
+
+// linkTemplates define source link formats for the known code hosts, each of them
+// has its own URL layout and the syntax of line anchors. Gitea and Azure DevOps links without a ref
+// point to the default branch.
+var linkTemplates = map[string]string{
+	LinkStylePlain:       `{{.Prefix}}{{.Path}}#L{{.Line}}`,
+	LinkStyleGithub:      `{{.Prefix}}/blob/{{.Ref}}/{{.Path}}#L{{.Line}}`,
+	LinkStyleGitlab:      `{{.Prefix}}/-/blob/{{.Ref}}/{{.Path}}#L{{.Line}}`,
+	LinkStyleBitbucket:   `{{.Prefix}}/src/{{.Ref}}/{{.Path}}#lines-{{.Line}}`,
+	LinkStyleGitea:       `{{.Prefix}}/src/{{with .RefKind}}{{.}}/{{$.Ref}}/{{end}}{{.Path}}#L{{.Line}}`,
+	LinkStyleAzure:       `{{.Prefix}}?path=/{{urlquery .Path}}{{with .RefKind}}&version=G{{if eq . "commit"}}C{{else if eq . "tag"}}T{{else}}B{{end}}{{urlquery $.Ref}}{{end}}&line={{.Line}}&lineEnd={{.Line}}&lineStartColumn=1&lineEndColumn=1&lineStyle=plain`,
+	LinkStyleSourcegraph: `{{.Prefix}}@{{.Ref}}/-/blob/{{.Path}}#L{{.Line}}`,
+	LinkStyleFile:        `file://{{.Prefix}}/{{.Path}}`,
+	LinkStyleVSCode:      `vscode://file{{.Prefix}}/{{.Path}}:{{.Line}}`,
+}
+
+// LinkStyles lists the names of supported source link styles.
+var LinkStyles = []string{
+	LinkStylePlain,
+	LinkStyleGithub,
+	LinkStyleGitlab,
+	LinkStyleBitbucket,
+	LinkStyleGitea,
+	LinkStyleAzure,
+	LinkStyleSourcegraph,
+	LinkStyleFile,
+	LinkStyleVSCode,
+}
+
+// DefaultRef is used in source links when no ref has been specified or detected.
+// Most of code hosts resolve it to the default branch, others link to the default
+// branch without the ref.
+const DefaultRef = "HEAD"
+
+// Kinds of refs, some code hosts have different URLs for them.
+const (
+	RefKindCommit = "commit"
+	RefKindBranch = "branch"
+	RefKindTag    = "tag"
+)
+
+// SourceLinker builds links to the source lines of crumbs.
+type SourceLinker struct {
+	Prefix string
+	Ref    string
+	// RefKind is commit, branch or tag, it's empty for DefaultRef.
+	RefKind string
+	Style   string
+	// Dir is the path of the project directory in the repository, it is prepended
+	// to source paths of the code host links.
+	Dir string
+
+	text string
+	tpl  *template.Template
+}
+
+type sourceLinkData struct {
+	Prefix   string
+	Ref      string
+	RefKind  string
+	IsCommit bool
+	Path     string
+	Line     int
+}
+
+// NewSourceLinker creates a linker for one of the known link styles, the style is detected
+// from the prefix if not specified.
+func NewSourceLinker(prefix, ref, style string) (*SourceLinker, error) {
+	if len(style) == 0 {
+		style = DetectLinkStyle(prefix)
+	}
+	text, ok := linkTemplates[style]
+	if !ok {
+		return nil, fmt.Errorf("unsupported link style: %s", style)
+	}
+	return NewSourceLinkerTemplate(prefix, ref, style, text)
+}
+
+// NewSourceLinkerTemplate creates a linker that uses a custom URL template. The template
+// gets .Prefix, .Ref, .RefKind, .IsCommit, .Path and .Line fields. The kind of ref is guessed,
+// set RefKind if it's known.
+func NewSourceLinkerTemplate(prefix, ref, style, text string) (*SourceLinker, error) {
+	tpl, err := template.New("link").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse link template: %v", err)
+	}
+	if len(ref) == 0 {
+		ref = DefaultRef
+	}
+	return &SourceLinker{
+		Prefix:  prefix,
+		Ref:     ref,
+		RefKind: GuessRefKind(ref),
+		Style:   style,
+
+		text: text,
+		tpl:  tpl,
+	}, nil
+}
+
+// WithSource returns a linker for another source, e.g. for crumbs merged from documents of
+// other projects. The style is detected anew, unless it has been specified explicitly.
+func (l *SourceLinker) WithSource(prefix, ref, dir string) *SourceLinker {
+	if l.text == linkTemplates[l.Style] && l.Style == DetectLinkStyle(l.Prefix) {
+		if linker, err := NewSourceLinker(prefix, ref, ""); err == nil {
+			linker.Dir = dir
+			return linker
+		}
+	}
+	linker := *l
+	linker.Prefix = prefix
+	linker.Ref = ref
+	if len(ref) == 0 {
+		linker.Ref = DefaultRef
+	}
+	linker.RefKind = GuessRefKind(linker.Ref)
+	linker.Dir = dir
+	return &linker
+}
+
+func (l *SourceLinker) Link(path string, line int) string {
+	prefix := l.Prefix
+	switch l.Style {
+	case LinkStylePlain:
+	case LinkStyleFile, LinkStyleVSCode:
+		prefix = strings.TrimPrefix(prefix, "file://")
+		prefix = strings.TrimPrefix(prefix, "vscode://file")
+		prefix = strings.TrimSuffix(prefix, "/")
+		if !strings.HasPrefix(prefix, "/") {
+			// Windows paths like C:/src must be prefixed as well
+			prefix = "/" + prefix
+		}
+	default:
+		if len(l.Dir) > 0 {
+			path = strings.TrimSuffix(l.Dir, "/") + "/" + strings.TrimPrefix(path, "/")
+		}
+		prefix, path = splitRepoPath(prefix, path)
+	}
+	buf := new(bytes.Buffer)
+	if err := l.tpl.Execute(buf, &sourceLinkData{
+		Prefix:   prefix,
+		Ref:      l.Ref,
+		RefKind:  l.RefKind,
+		IsCommit: l.RefKind == RefKindCommit,
+		Path:     path,
+		Line:     line,
+	}); err != nil {
+		return prefix + path
+	}
+	return buf.String()
+}
+
+var fullCommitRx = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// GuessRefKind is used when the ref cannot be looked up in the repository. Only full commit SHAs
+// are taken for commits, as short ones cannot be told from branch names.
+func GuessRefKind(ref string) string {
+	switch {
+	case ref == DefaultRef:
+		return ""
+	case fullCommitRx.MatchString(ref):
+		return RefKindCommit
+	}
+	return RefKindBranch
+}
+
+// splitRepoPath normalises the prefix of repository URL. If the prefix points to
+// an organisation rather than a repository, the repository name is taken from the path.
+func splitRepoPath(prefix, path string) (string, string) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	u, err := url.Parse(prefix)
+	if err != nil || len(u.Host) == 0 {
+		return prefix, path
+	}
+	if strings.Count(strings.Trim(u.Path, "/"), "/") == 0 && isGitHost(u.Host) {
+		pathParts := strings.Split(path, "/")
+		repoName := pathParts[0]
+		path = strings.Join(pathParts[1:], "/")
+		return prefix + "/" + repoName, path
+	}
+	return prefix, path
+}
+
+func isGitHost(host string) bool {
+	return host == "github.com" || host == "gitlab.com" || host == "bitbucket.org"
+}
+
+// DetectLinkStyle guesses the link style by the source prefix.
+func DetectLinkStyle(prefix string) string {
+	u, err := url.Parse(prefix)
+	if err != nil {
+		return LinkStylePlain
+	}
+	host := strings.ToLower(u.Host)
+	switch {
+	case u.Scheme == "file":
+		return LinkStyleFile
+	case u.Scheme == "vscode":
+		return LinkStyleVSCode
+	case host == "github.com" || strings.HasPrefix(host, "github."):
+		return LinkStyleGithub
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return LinkStyleGitlab
+	case host == "bitbucket.org" || strings.HasPrefix(host, "bitbucket."):
+		return LinkStyleBitbucket
+	case host == "codeberg.org" || strings.HasPrefix(host, "gitea."):
+		return LinkStyleGitea
+	case host == "dev.azure.com" || strings.HasSuffix(host, ".visualstudio.com"):
+		return LinkStyleAzure
+	case strings.Contains(host, "sourcegraph"):
+		return LinkStyleSourcegraph
+	}
+	return LinkStylePlain
+}
@@ -3,10 +3,13 @@ package generator
 import (
 	"bytes"
 	"fmt"
+	"io/ioutil"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/xlab/treeprint"
 
@@ -17,31 +20,115 @@ type Markdown struct {
 	ProjectName  string
 	ProjectEntry string
 	SourcePrefix string
+	Linker       *SourceLinker
+
+	// LinkBase is prepended to relative source links, when rendering files that are
+	// located deeper than the project root.
+	LinkBase string
+
+	tpl *template.Template
 }
 
 func NewMarkdownGenerator(projectName, projectEntry, sourcePrefix string) *Markdown {
-	return &Markdown{
+	m := &Markdown{
 		ProjectName:  projectName,
 		ProjectEntry: projectEntry,
 		SourcePrefix: sourcePrefix,
 	}
+	m.Linker, _ = NewSourceLinker(sourcePrefix, "", "")
+	m.tpl = template.Must(template.New("markdown").Funcs(m.templateFuncs()).Parse(defaultTemplates))
+	return m
 }
 
-func joinPrefixPath(prefix, path string) string {
-	if strings.HasPrefix(prefix, "https://github.com") ||
-		strings.HasPrefix(prefix, "https://gitlab.com") {
-		prefix = strings.TrimPrefix(prefix, "https://")
-		prefix = strings.TrimSuffix(prefix, "/")
-		prefixParts := strings.Split(prefix, "/")
-		if len(prefixParts) == 2 {
-			pathParts := strings.Split(path, "/")
-			repoName := pathParts[0]
-			path = strings.Join(pathParts[1:], "/")
-			return fmt.Sprintf("https://%s/%s/blob/master/%s", prefix, repoName, path)
+// LoadTemplates overrides the default partials with templates found in dir. Each *.tmpl file
+// defines a partial named after the file, e.g. step.tmpl replaces the "step" partial.
+func (m *Markdown) LoadTemplates(dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmpl"))
+	if err != nil {
+		return err
+	} else if len(files) == 0 {
+		return fmt.Errorf("no *.tmpl files found in %s", dir)
+	}
+	for _, file := range files {
+		body, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".tmpl")
+		if _, err := m.tpl.New(name).Parse(string(body)); err != nil {
+			return err
 		}
-		return fmt.Sprintf("https://%s/blob/master/%s", prefix, path)
 	}
-	return prefix + path
+	return nil
+}
+
+func (m *Markdown) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"anchor":     anchor,
+		"title":      strings.Title,
+		"lower":      strings.ToLower,
+		"fence":      parser.FenceFor,
+		"tree":       treeForTrail,
+		"peek":       peekLines,
+		"sourceLink": m.sourceLink,
+	}
+}
+
+func (m *Markdown) sourceLink(cc *parser.CodeCrumb) string {
+	linker := m.Linker
+	if src := cc.Source; src != nil {
+		linker = linker.WithSource(src.Prefix, src.Ref, src.Dir)
+	}
+	link := linker.Link(cc.SourcePath, cc.SourceLine)
+	if !strings.Contains(link, "://") && !strings.HasPrefix(link, "/") {
+		link = m.LinkBase + link
+	}
+	return link
+}
+
+type documentData struct {
+	ProjectName  string
+	ProjectEntry string
+	Stats        documentStats
+	MainTrails   []trailData
+	SideTrails   []trailData
+	Remarks      []remarkData
+	RemarkGroups []remarkGroup
+}
+
+type documentStats struct {
+	Total   int
+	Main    int
+	Side    int
+	Remarks int
+}
+
+type trailData struct {
+	Name   string
+	File   string
+	Crumbs []*parser.CodeCrumb
+
+	// Elsewhere links to parts of the trail in documents of other modules.
+	Elsewhere []moduleLink
+}
+
+type remarkData struct {
+	*parser.CodeCrumb
+
+	Heading string
+	Anchor  string
+}
+
+type remarkGroup struct {
+	Package string
+	Anchor  string
+	Remarks []remarkData
+}
+
+type trailPageData struct {
+	ProjectName string
+	IsMain      bool
+	Trail       trailData
 }
 
 func (m *Markdown) RenderDocument(
@@ -49,181 +136,234 @@ func (m *Markdown) RenderDocument(
 	sideTrails map[string][]*parser.CodeCrumb,
 	remarks []*parser.CodeCrumb,
 ) ([]byte, error) {
-	buf := new(bytes.Buffer)
+	data := m.newDocumentData(mainTrails, sideTrails, remarks)
+	data.Remarks = newRemarks(remarks)
+	return m.execute("document", data)
+}
 
-	var (
-		statsMain    int
-		statsSide    int
-		statsRemarks int
-		statsTotal   int
-	)
-	for _, crumbs := range mainTrails {
-		statsMain++
-		for range crumbs {
-			statsTotal++
-		}
-	}
-	for _, crumbs := range sideTrails {
-		statsSide++
-		for range crumbs {
-			statsTotal++
+// RenderTOC renders the table of contents of trails and remarks rendered separately by RenderTrail
+// and RenderRemarks, unlike the document TOC it has no links to the Main Trails, Side Trails and
+// Remarks sections.
+func (m *Markdown) RenderTOC(
+	mainTrails map[string][]*parser.CodeCrumb,
+	sideTrails map[string][]*parser.CodeCrumb,
+	remarks []*parser.CodeCrumb,
+) ([]byte, error) {
+	data := m.newDocumentData(mainTrails, sideTrails, remarks)
+	data.Remarks = newRemarks(remarks)
+	return m.execute("sections-toc", data)
+}
+
+// RenderIntro renders only the introduction of the document with the stats.
+func (m *Markdown) RenderIntro(
+	mainTrails map[string][]*parser.CodeCrumb,
+	sideTrails map[string][]*parser.CodeCrumb,
+	remarks []*parser.CodeCrumb,
+) ([]byte, error) {
+	return m.execute("intro", m.newDocumentData(mainTrails, sideTrails, remarks))
+}
+
+// RenderTrail renders a single trail, as it appears in the document.
+func (m *Markdown) RenderTrail(name string, trail []*parser.CodeCrumb) ([]byte, error) {
+	return m.execute("trail", trailData{
+		Name:   name,
+		Crumbs: trail,
+	})
+}
+
+// RenderRemarks renders the list of remarks, as they appear in the document.
+func (m *Markdown) RenderRemarks(remarks []*parser.CodeCrumb) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, remark := range newRemarks(remarks) {
+		if err := m.tpl.ExecuteTemplate(buf, "remark", remark); err != nil {
+			return nil, err
 		}
 	}
-	for range remarks {
-		statsRemarks++
-		statsTotal++
-	}
+	return buf.Bytes(), nil
+}
 
-	fmt.Fprintf(buf, "# %s\n\n", strings.Title(m.ProjectName))
+func (m *Markdown) execute(name string, data interface{}) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if err := m.tpl.ExecuteTemplate(buf, name, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
 
-	fmt.Fprintf(buf, "❓ This document has been generated using [cc-go](https://github.com/AtlantPlatform/codecrumbs-go)"+
-		" tool. Running for **%s** project it found **%d** codecrumbs in total. There are **%d** main trails of codecrumbs,"+
-		" that are crossing the project's entrypoint, also **%d** side trails and **%d** standalone remarks.\n\n",
-		m.ProjectName, statsTotal, statsMain, statsSide, statsRemarks)
+const (
+	SplitIndexFile   = "index.md"
+	SplitRemarksFile = "remarks.md"
+	SplitTrailsDir   = "trails"
+)
 
-	var mainNames []string
-	var sideNames []string
+// RenderSplit renders the documentation as a set of files, keyed by their relative paths:
+// an index with the TOC and stats, a file per trail and a file with remarks grouped by package.
+func (m *Markdown) RenderSplit(
+	mainTrails map[string][]*parser.CodeCrumb,
+	sideTrails map[string][]*parser.CodeCrumb,
+	remarks []*parser.CodeCrumb,
+) (map[string][]byte, error) {
+	data := m.newDocumentData(mainTrails, sideTrails, remarks)
+	data.RemarkGroups = groupRemarks(remarks)
 
-	if len(mainTrails) > 0 {
-		mainNames = make([]string, 0, len(mainTrails))
-		for name := range mainTrails {
-			mainNames = append(mainNames, name)
+	files := make(map[string][]byte, len(data.MainTrails)+len(data.SideTrails)+2)
+	render := func(file, name string, data interface{}) error {
+		buf, err := m.execute(name, data)
+		if err != nil {
+			return err
 		}
-		sort.Strings(mainNames)
-
-		fmt.Fprintf(buf, "- [Main Trails](%s)\n", anchor("Main Trails"))
-		for _, name := range mainNames {
-			fmt.Fprintf(buf, "  - [%s](%s)\n",
-				strings.Title(name), anchor(name))
+		files[file] = buf
+		return nil
+	}
+	if err := render(SplitIndexFile, "index", data); err != nil {
+		return nil, err
+	}
+	linkBase := m.LinkBase
+	m.LinkBase = "../" + linkBase
+	defer func() {
+		m.LinkBase = linkBase
+	}()
+	for _, trail := range data.MainTrails {
+		if err := render(trail.File, "trail-page", &trailPageData{
+			ProjectName: m.ProjectName,
+			IsMain:      true,
+			Trail:       trail,
+		}); err != nil {
+			return nil, err
 		}
 	}
-	if len(sideTrails) > 0 {
-		sideNames = make([]string, 0, len(sideTrails))
-		for name := range sideTrails {
-			sideNames = append(sideNames, name)
+	for _, trail := range data.SideTrails {
+		if err := render(trail.File, "trail-page", &trailPageData{
+			ProjectName: m.ProjectName,
+			Trail:       trail,
+		}); err != nil {
+			return nil, err
 		}
-		sort.Strings(sideNames)
-
-		fmt.Fprintf(buf, "- [Side Trails](%s)\n", anchor("Side Trails"))
-		for _, name := range sideNames {
-			fmt.Fprintf(buf, "  - [%s](%s)\n",
-				strings.Title(name), anchor(name))
+	}
+	m.LinkBase = linkBase
+	if len(data.RemarkGroups) > 0 {
+		if err := render(SplitRemarksFile, "remarks-page", data); err != nil {
+			return nil, err
 		}
 	}
+	return files, nil
+}
+
+func (m *Markdown) newDocumentData(
+	mainTrails map[string][]*parser.CodeCrumb,
+	sideTrails map[string][]*parser.CodeCrumb,
+	remarks []*parser.CodeCrumb,
+) *documentData {
+	data := &documentData{
+		ProjectName:  m.ProjectName,
+		ProjectEntry: m.ProjectEntry,
+	}
+	// main and side trails share the directory of split trail files
+	files := make(FileNames)
+	data.MainTrails = sortedTrails(mainTrails, files)
+	data.SideTrails = sortedTrails(sideTrails, files)
+	for _, crumbs := range mainTrails {
+		data.Stats.Main++
+		data.Stats.Total += len(crumbs)
+	}
+	for _, crumbs := range sideTrails {
+		data.Stats.Side++
+		data.Stats.Total += len(crumbs)
+	}
+	data.Stats.Remarks = len(remarks)
+	data.Stats.Total += len(remarks)
+	return data
+}
+
+// newRemarks prepares headings and unique anchors of remarks, as they appear in a single file.
+func newRemarks(remarks []*parser.CodeCrumb) []remarkData {
+	list := make([]remarkData, 0, len(remarks))
 	var anchorsSeen = map[string]int{}
-	if len(remarks) > 0 {
-		fmt.Fprintf(buf, "- [Remarks](%s)\n", anchor("Remarks"))
-		for _, cc := range remarks {
-			var title string
-			if len(cc.Title) > 0 {
-				title = fmt.Sprintf("L%d: %s", cc.SourceLine, strings.Title(cc.Title))
-			} else {
-				title = fmt.Sprintf("L%d", cc.SourceLine)
-			}
-			anchorTitle := anchor(title)
-			seenTimes := anchorsSeen[anchorTitle]
-			anchorsSeen[anchorTitle]++
-			if seenTimes > 0 {
-				anchorTitle = fmt.Sprintf("%s-%d", anchorTitle, seenTimes)
-			}
-			fmt.Fprintf(buf, "  - [%s](%s)\n", title, anchorTitle)
+	for _, cc := range remarks {
+		var title string
+		if len(cc.Title) > 0 {
+			title = fmt.Sprintf("L%d: %s", cc.SourceLine, strings.Title(cc.Title))
+		} else {
+			title = fmt.Sprintf("L%d", cc.SourceLine)
 		}
-	}
-	fmt.Fprintf(buf, "\n")
-
-	renderTrail := func(trailName string, trail []*parser.CodeCrumb) {
-		fmt.Fprintf(buf, "### %s\n\n", strings.Title(trailName))
-
-		fmt.Fprintf(buf, "~~~\n")
-		fmt.Fprintf(buf, "%s", treeForTrail(trail))
-		fmt.Fprintf(buf, "~~~\n\n")
-
-		for _, cc := range trail {
-			if len(cc.Title) > 0 {
-				fmt.Fprintf(buf, "#### %d. %s\n\n",
-					cc.TrailStep, strings.Title(cc.Title),
-				)
-			} else {
-				fmt.Fprintf(buf, "#### %d.\n\n",
-					cc.TrailStep,
-				)
-			}
-			if len(cc.DescLines) > 0 {
-				for _, line := range cc.DescLines {
-					fmt.Fprintf(buf, "%s\n", line)
-				}
-				fmt.Fprintf(buf, "\n")
-			}
-			fmt.Fprintf(buf, "📖 [%s:%d](%s#L%d)\n\n",
-				cc.SourcePath, cc.SourceLine, joinPrefixPath(m.SourcePrefix, cc.SourcePath), cc.SourceLine)
-			if len(cc.PeekedLines) > 0 {
-				lines, modified := trimTabPrefix(cc.PeekedLines)
-				for modified {
-					lines, modified = trimTabPrefix(lines)
-				}
-				lines, modified = trimSpacePrefix(lines)
-				for modified {
-					lines, modified = trimSpacePrefix(lines)
-				}
-				fmt.Fprintf(buf, "~~~%s\n", strings.ToLower(cc.LanguageName))
-				for _, line := range lines {
-					fmt.Fprintf(buf, "%s\n", line)
-				}
-				fmt.Fprintf(buf, "~~~\n\n")
-			}
+		anchorTitle := anchor(title)
+		seenTimes := anchorsSeen[anchorTitle]
+		anchorsSeen[anchorTitle]++
+		if seenTimes > 0 {
+			anchorTitle = fmt.Sprintf("%s-%d", anchorTitle, seenTimes)
 		}
+		list = append(list, remarkData{
+			CodeCrumb: cc,
+			Heading:   title,
+			Anchor:    anchorTitle,
+		})
 	}
+	return list
+}
 
-	if len(mainTrails) > 0 {
-		fmt.Fprintf(buf, "## Main Trails\n\n")
-		for _, name := range mainNames {
-			renderTrail(name, mainTrails[name])
+// groupRemarks groups remarks by the package (directory) of their source files.
+func groupRemarks(remarks []*parser.CodeCrumb) []remarkGroup {
+	byPackage := make(map[string][]*parser.CodeCrumb)
+	var packages []string
+	for _, cc := range remarks {
+		pkg := filepath.ToSlash(filepath.Dir(cc.SourcePath))
+		if _, ok := byPackage[pkg]; !ok {
+			packages = append(packages, pkg)
 		}
+		byPackage[pkg] = append(byPackage[pkg], cc)
 	}
-	if len(sideTrails) > 0 {
-		fmt.Fprintf(buf, "## Side Trails\n\n")
-		for _, name := range sideNames {
-			renderTrail(name, sideTrails[name])
-		}
+	sort.Strings(packages)
+	ordered := make([]*parser.CodeCrumb, 0, len(remarks))
+	for _, pkg := range packages {
+		ordered = append(ordered, byPackage[pkg]...)
 	}
+	list := newRemarks(ordered)
+	groups := make([]remarkGroup, 0, len(packages))
+	for _, pkg := range packages {
+		n := len(byPackage[pkg])
+		groups = append(groups, remarkGroup{
+			Package: pkg,
+			Anchor:  headingAnchor(pkg),
+			Remarks: list[:n],
+		})
+		list = list[n:]
+	}
+	return groups
+}
 
-	if len(remarks) > 0 {
-		fmt.Fprintf(buf, "## Remarks\n\n")
-		for _, cc := range remarks {
-			if len(cc.Title) > 0 {
-				fmt.Fprintf(buf, "### L%d: %s\n\n",
-					cc.SourceLine, strings.Title(cc.Title),
-				)
-			} else {
-				fmt.Fprintf(buf, "### L%d\n\n", cc.SourceLine)
-			}
-			if len(cc.DescLines) > 0 {
-				for _, line := range cc.DescLines {
-					fmt.Fprintf(buf, "%s\n", line)
-				}
-				fmt.Fprintf(buf, "\n")
-			}
-			fmt.Fprintf(buf, "📖 [%s:%d](%s#L%d)\n\n",
-				cc.SourcePath, cc.SourceLine, joinPrefixPath(m.SourcePrefix, cc.SourcePath), cc.SourceLine)
-			if len(cc.PeekedLines) > 0 {
-				lines, modified := trimTabPrefix(cc.PeekedLines)
-				for modified {
-					lines, modified = trimTabPrefix(lines)
-				}
-				lines, modified = trimSpacePrefix(lines)
-				for modified {
-					lines, modified = trimSpacePrefix(lines)
-				}
-				fmt.Fprintf(buf, "~~~%s\n", strings.ToLower(cc.LanguageName))
-				for _, line := range lines {
-					fmt.Fprintf(buf, "%s\n", line)
-				}
-				fmt.Fprintf(buf, "~~~\n\n")
-			}
-		}
+func sortedTrails(trails map[string][]*parser.CodeCrumb, files FileNames) []trailData {
+	names := make([]string, 0, len(trails))
+	for name := range trails {
+		names = append(names, name)
 	}
+	sort.Strings(names)
+	sorted := make([]trailData, 0, len(names))
+	for _, name := range names {
+		sorted = append(sorted, trailData{
+			Name:   name,
+			File:   path.Join(SplitTrailsDir, files.Take(strings.TrimPrefix(anchor(name), "#"), "trail")+".md"),
+			Crumbs: trails[name],
+		})
+	}
+	return sorted
+}
 
-	return buf.Bytes(), nil
+// peekLines returns peeked lines of the crumb with the common indentation removed.
+func peekLines(cc *parser.CodeCrumb) []string {
+	if len(cc.PeekedLines) == 0 {
+		return nil
+	}
+	lines := make([]string, len(cc.PeekedLines))
+	copy(lines, cc.PeekedLines)
+	lines, modified := trimTabPrefix(lines)
+	for modified {
+		lines, modified = trimTabPrefix(lines)
+	}
+	lines, modified = trimSpacePrefix(lines)
+	for modified {
+		lines, modified = trimSpacePrefix(lines)
+	}
+	return lines
 }
 
 func trimTabPrefix(lines []string) ([]string, bool) {
@@ -261,6 +401,15 @@ func anchor(title string) string {
 	return "#" + strings.ToLower(strings.Join(words, "-"))
 }
 
+var headingPunctRx = regexp.MustCompile(`[^\w\- ]`)
+
+// headingAnchor returns an anchor of the heading as generated by GitHub: punctuation is removed
+// rather than replaced, so it's suitable for headings containing paths.
+func headingAnchor(heading string) string {
+	heading = headingPunctRx.ReplaceAllString(strings.ToLower(heading), "")
+	return "#" + strings.Replace(heading, " ", "-", -1)
+}
+
 func treeForTrail(trail []*parser.CodeCrumb) string {
 	tree := treeprint.New()
 
@@ -0,0 +1,243 @@
+package generator
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/AtlantPlatform/codecrumbs-go/parser"
+)
+
+const (
+	ModulesIndexFile = "index.md"
+	ModulesDir       = "modules"
+)
+
+// OtherModule is the name of the document with crumbs found outside of any module.
+const OtherModule = "other"
+
+type moduleData struct {
+	Path    string
+	File    string
+	Trails  int
+	Remarks int
+}
+
+type moduleLink struct {
+	Path string
+	File string
+}
+
+type crossTrail struct {
+	Name    string
+	Modules []moduleLink
+}
+
+type modulesIndexData struct {
+	*documentData
+
+	Modules     []moduleData
+	CrossTrails []crossTrail
+}
+
+type modulePageData struct {
+	*documentData
+
+	Module moduleData
+}
+
+// RenderModules renders a document per module of the project and an index of modules,
+// keyed by their relative paths. Crumbs are assigned to modules by their module tag, parts
+// of a trail that spans multiple modules are linked across the documents.
+func (m *Markdown) RenderModules(
+	mainTrails map[string][]*parser.CodeCrumb,
+	sideTrails map[string][]*parser.CodeCrumb,
+	remarks []*parser.CodeCrumb,
+) (map[string][]byte, error) {
+	type moduleCrumbs struct {
+		mainTrails map[string][]*parser.CodeCrumb
+		sideTrails map[string][]*parser.CodeCrumb
+		remarks    []*parser.CodeCrumb
+	}
+	byModule := make(map[string]*moduleCrumbs)
+	moduleOf := func(cc *parser.CodeCrumb) *moduleCrumbs {
+		mc, ok := byModule[cc.Module]
+		if !ok {
+			mc = &moduleCrumbs{
+				mainTrails: make(map[string][]*parser.CodeCrumb),
+				sideTrails: make(map[string][]*parser.CodeCrumb),
+			}
+			byModule[cc.Module] = mc
+		}
+		return mc
+	}
+	// trailModules keeps modules of each trail in order of their first step
+	trailModules := make(map[string][]string)
+	splitTrails := func(trails map[string][]*parser.CodeCrumb, isMain bool) {
+		for name, trail := range trails {
+			for _, cc := range trail {
+				mc := moduleOf(cc)
+				if isMain {
+					mc.mainTrails[name] = append(mc.mainTrails[name], cc)
+				} else {
+					mc.sideTrails[name] = append(mc.sideTrails[name], cc)
+				}
+				if !containsString(trailModules[name], cc.Module) {
+					trailModules[name] = append(trailModules[name], cc.Module)
+				}
+			}
+		}
+	}
+	splitTrails(mainTrails, true)
+	splitTrails(sideTrails, false)
+	for _, cc := range remarks {
+		mc := moduleOf(cc)
+		mc.remarks = append(mc.remarks, cc)
+	}
+
+	modules := make([]string, 0, len(byModule))
+	for module := range byModule {
+		modules = append(modules, module)
+	}
+	// crumbs outside of modules go last
+	sort.Slice(modules, func(i, j int) bool {
+		if len(modules[i]) == 0 || len(modules[j]) == 0 {
+			return len(modules[j]) == 0 && len(modules[i]) > 0
+		}
+		return modules[i] < modules[j]
+	})
+	files := moduleFiles(modules)
+	// linksTo returns links to the trail in documents of other modules, relative to the index
+	// or to the module documents directory.
+	linksTo := func(trail string, except string, fromModule bool) []moduleLink {
+		var links []moduleLink
+		for _, module := range trailModules[trail] {
+			if module == except {
+				continue
+			}
+			file := files[module]
+			if fromModule {
+				file = path.Base(file)
+			}
+			links = append(links, moduleLink{
+				Path: moduleName(module),
+				File: file + anchor(trail),
+			})
+		}
+		return links
+	}
+
+	index := &modulesIndexData{
+		documentData: m.newDocumentData(mainTrails, sideTrails, remarks),
+	}
+	for _, module := range modules {
+		mc := byModule[module]
+		index.Modules = append(index.Modules, moduleData{
+			Path:    moduleName(module),
+			File:    files[module],
+			Trails:  len(mc.mainTrails) + len(mc.sideTrails),
+			Remarks: len(mc.remarks),
+		})
+	}
+	for _, trail := range append(index.MainTrails, index.SideTrails...) {
+		if len(trailModules[trail.Name]) > 1 {
+			index.CrossTrails = append(index.CrossTrails, crossTrail{
+				Name:    trail.Name,
+				Modules: linksTo(trail.Name, "", false),
+			})
+		}
+	}
+	sort.Slice(index.CrossTrails, func(i, j int) bool {
+		return index.CrossTrails[i].Name < index.CrossTrails[j].Name
+	})
+
+	result := make(map[string][]byte, len(modules)+1)
+	buf, err := m.execute("modules-index", index)
+	if err != nil {
+		return nil, err
+	}
+	result[ModulesIndexFile] = buf
+
+	linkBase := m.LinkBase
+	m.LinkBase = "../" + linkBase
+	defer func() {
+		m.LinkBase = linkBase
+	}()
+	for i, module := range modules {
+		mc := byModule[module]
+		data := &modulePageData{
+			documentData: m.newDocumentData(mc.mainTrails, mc.sideTrails, mc.remarks),
+			Module:       index.Modules[i],
+		}
+		data.Remarks = newRemarks(mc.remarks)
+		for j, trail := range data.MainTrails {
+			data.MainTrails[j].Elsewhere = linksTo(trail.Name, module, true)
+		}
+		for j, trail := range data.SideTrails {
+			data.SideTrails[j].Elsewhere = linksTo(trail.Name, module, true)
+		}
+		buf, err := m.execute("module-page", data)
+		if err != nil {
+			return nil, err
+		}
+		result[files[module]] = buf
+	}
+	return result, nil
+}
+
+// moduleFiles assigns file names to module documents, the common prefix of
+// module paths is omitted to keep the names short. Names are unique as of FileNames.
+func moduleFiles(modules []string) map[string]string {
+	var common []string
+	var seen bool
+	for _, module := range modules {
+		if len(module) == 0 {
+			continue
+		}
+		parts := strings.Split(module, "/")
+		parts = parts[:len(parts)-1]
+		if !seen {
+			common, seen = parts, true
+			continue
+		}
+		n := 0
+		for n < len(common) && n < len(parts) && common[n] == parts[n] {
+			n++
+		}
+		common = common[:n]
+	}
+	prefix := strings.Join(common, "/")
+	files := make(map[string]string, len(modules))
+	names := make(FileNames)
+	// the document of crumbs outside of modules keeps its name, modules are named after it
+	for _, module := range modules {
+		if len(module) == 0 {
+			files[module] = path.Join(ModulesDir, names.Take(OtherModule, "")+".md")
+		}
+	}
+	for _, module := range modules {
+		if len(module) == 0 {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(module, prefix), "/")
+		name = names.Take(strings.TrimPrefix(anchor(name), "#"), "module")
+		files[module] = path.Join(ModulesDir, name+".md")
+	}
+	return files
+}
+
+func moduleName(module string) string {
+	if len(module) == 0 {
+		return strings.Title(OtherModule)
+	}
+	return module
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package generator
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FileNames hands out names of the generated files that are unique regardless of the case,
+// so they don't overwrite each other on case-insensitive file systems either. Empty names
+// are replaced with the fallback, names that are already taken get a numeric suffix, e.g. auth-2.
+type FileNames map[string]bool
+
+// Take returns a unique name based on the given one and marks it as taken.
+func (f FileNames) Take(name, fallback string) string {
+	if len(name) == 0 {
+		name = fallback
+	}
+	file := name
+	for n := 2; f[strings.ToLower(file)]; n++ {
+		file = fmt.Sprintf("%s-%d", name, n)
+	}
+	f[strings.ToLower(file)] = true
+	return file
+}
@@ -0,0 +1,167 @@
+package generator
+
+// defaultTemplates define the layout of the generated Markdown document. Any of the partials
+// (document, toc, trail, step, remark) can be overridden by user-supplied templates. The split output
+// additionally uses index, trail-page and remarks-page partials, the output per module uses
+// modules-index and module-page partials. Sections injected into other files are listed
+// by the sections-toc partial. The coverage partial renders the report of cc-go stats.
+const defaultTemplates = `
+{{- define "intro" -}}
+❓ This document has been generated using [cc-go](https://github.com/AtlantPlatform/codecrumbs-go) tool. Running for **{{.ProjectName}}** project it found **{{.Stats.Total}}** codecrumbs in total. There are **{{.Stats.Main}}** main trails of codecrumbs, that are crossing the project's entrypoint, also **{{.Stats.Side}}** side trails and **{{.Stats.Remarks}}** standalone remarks.
+{{end -}}
+
+{{define "toc" -}}
+{{if .MainTrails}}- [Main Trails]({{anchor "Main Trails"}})
+{{range .MainTrails}}  - [{{title .Name}}]({{anchor .Name}})
+{{end}}{{end -}}
+{{if .SideTrails}}- [Side Trails]({{anchor "Side Trails"}})
+{{range .SideTrails}}  - [{{title .Name}}]({{anchor .Name}})
+{{end}}{{end -}}
+{{if .Remarks}}- [Remarks]({{anchor "Remarks"}})
+{{range .Remarks}}  - [{{.Heading}}]({{.Anchor}})
+{{end}}{{end -}}
+{{end -}}
+
+{{define "sections-toc" -}}
+{{range .MainTrails}}- [{{title .Name}}]({{anchor .Name}})
+{{end -}}
+{{range .SideTrails}}- [{{title .Name}}]({{anchor .Name}})
+{{end -}}
+{{range .Remarks}}- [{{.Heading}}]({{.Anchor}})
+{{end -}}
+{{end -}}
+
+{{define "document" -}}
+# {{title .ProjectName}}
+
+{{template "intro" .}}
+{{template "toc" .}}
+{{template "sections" .}}
+{{- end -}}
+
+{{define "sections" -}}
+{{if .MainTrails}}## Main Trails
+
+{{range .MainTrails}}{{template "trail" .}}{{end}}{{end -}}
+{{if .SideTrails}}## Side Trails
+
+{{range .SideTrails}}{{template "trail" .}}{{end}}{{end -}}
+{{if .Remarks}}## Remarks
+
+{{range .Remarks}}{{template "remark" .}}{{end}}{{end -}}
+{{end -}}
+
+{{define "trail" -}}
+### {{title .Name}}
+
+{{with .Elsewhere}}🔗 Other parts of this trail: {{range $i, $m := .}}{{if $i}}, {{end}}[{{$m.Path}}]({{$m.File}}){{end}}.
+
+{{end -}}
+~~~
+{{tree .Crumbs}}~~~
+
+{{range .Crumbs}}{{template "step" .}}{{end}}
+{{- end -}}
+
+{{define "step" -}}
+#### {{.TrailStep}}.{{if .Title}} {{title .Title}}{{end}}
+
+{{if .DescLines}}{{range .DescLines}}{{.}}
+{{end}}
+{{end -}}
+📖 [{{.SourcePath}}:{{.SourceLine}}]({{sourceLink .}})
+
+{{with peek .}}~~~{{fence $.LanguageName}}
+{{range .}}{{.}}
+{{end}}~~~
+
+{{end}}
+{{- end -}}
+
+{{define "remark" -}}
+### {{.Heading}}
+
+{{if .DescLines}}{{range .DescLines}}{{.}}
+{{end}}
+{{end -}}
+📖 [{{.SourcePath}}:{{.SourceLine}}]({{sourceLink .CodeCrumb}})
+
+{{with peek .CodeCrumb}}~~~{{fence $.LanguageName}}
+{{range .}}{{.}}
+{{end}}~~~
+
+{{end}}
+{{- end -}}
+
+{{define "index" -}}
+# {{title .ProjectName}}
+
+{{template "intro" .}}
+{{if .MainTrails}}- Main Trails
+{{range .MainTrails}}  - [{{title .Name}}]({{.File}})
+{{end}}{{end -}}
+{{if .SideTrails}}- Side Trails
+{{range .SideTrails}}  - [{{title .Name}}]({{.File}})
+{{end}}{{end -}}
+{{if .RemarkGroups}}- [Remarks](remarks.md)
+{{range .RemarkGroups}}  - [{{.Package}}](remarks.md{{.Anchor}})
+{{range .Remarks}}    - [{{.Heading}}](remarks.md{{.Anchor}})
+{{end}}{{end}}{{end -}}
+{{end -}}
+
+{{define "trail-page" -}}
+# {{title .ProjectName}}
+
+[Index](../index.md) / {{if .IsMain}}Main Trails{{else}}Side Trails{{end}}
+
+{{template "trail" .Trail}}
+{{- end -}}
+
+{{define "remarks-page" -}}
+# {{title .ProjectName}}
+
+[Index](index.md) / Remarks
+
+{{range .RemarkGroups}}## {{.Package}}
+
+{{range .Remarks}}{{template "remark" .}}{{end}}{{end}}
+{{- end -}}
+
+{{define "modules-index" -}}
+# {{title .ProjectName}}
+
+{{template "intro" .}}
+| Module | Trails | Remarks |
+|---|---|---|
+{{range .Modules}}| [{{.Path}}]({{.File}}) | {{.Trails}} | {{.Remarks}} |
+{{end}}
+{{- if .CrossTrails}}
+## Trails Across Modules
+
+{{range .CrossTrails}}- {{title .Name}}: {{range $i, $m := .Modules}}{{if $i}} → {{end}}[{{$m.Path}}]({{$m.File}}){{end}}
+{{end}}{{end -}}
+{{end -}}
+
+{{define "coverage" -}}
+## Documentation Coverage
+
+| Directory | Lines | Crumbs | Trails | Remarks | Crumbs per KLOC | Undocumented Exports |
+|---|--:|--:|--:|--:|--:|--:|
+{{range .Packages}}{{template "coverage-row" .}}{{end -}}
+{{with .Total}}| **Total** | {{.Lines}} | {{.Crumbs}} | {{.Trails}} | {{.Remarks}} | {{printf "%.1f" .CrumbsPerKLOC}} | {{if .Exported}}{{.Undocumented}} of {{.Exported}}{{else}}-{{end}} |
+{{end -}}
+{{end -}}
+
+{{define "coverage-row" -}}
+| {{if .Package}}{{.Package}}{{else}}{{.Path}}{{end}} | {{.Lines}} | {{.Crumbs}} | {{.Trails}} | {{.Remarks}} | {{printf "%.1f" .CrumbsPerKLOC}} | {{if .Exported}}{{.Undocumented}} of {{.Exported}}{{else}}-{{end}} |
+{{end -}}
+
+{{define "module-page" -}}
+# {{.Module.Path}}
+
+[Index](../index.md) / {{.Module.Path}}
+
+{{template "toc" .}}
+{{template "sections" .}}
+{{- end -}}
+`
@@ -8,4 +8,5 @@ require (
 	github.com/sirupsen/logrus v1.4.2
 	github.com/xlab/catcher v0.0.0-20170222110830-4ce3d20cbee2
 	github.com/xlab/treeprint v0.0.0-20181112141820-a009c3971eca
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,144 @@
+package parser
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// BlockComment defines delimiters of comments that may span multiple lines, e.g. <!-- and -->.
+// Only comments starting at the beginning of a line are recognized.
+type BlockComment struct {
+	Start string
+	End   string
+}
+
+// Region is a part of the file written in another language, e.g. a fenced code block in Markdown.
+// Start and End are regexps matching the lines around the region, the language is taken from the
+// "lang" named group of Start, or Language if the group is missing or empty.
+type Region struct {
+	Start    string
+	End      string
+	Language string
+
+	// lang is the definition used instead of looking up Language by name, e.g. for the code
+	// regions of PHP files, that share the name with the definition of files.
+	lang *LanguageDefinition
+}
+
+type regionParsed struct {
+	*Region
+
+	start *regexp.Regexp
+	end   *regexp.Regexp
+}
+
+// LanguageForName finds a language by its name, Markdown fence name or extension, e.g.
+// "Python", "py" or "javascript".
+func LanguageForName(name string) (*LanguageDefinition, bool) {
+	if len(name) == 0 {
+		return nil, false
+	}
+	for _, def := range SupportedLangs {
+		if strings.EqualFold(def.Name, name) || strings.EqualFold(def.Fence, name) {
+			def := def
+			return &def, true
+		}
+	}
+	return LanguageFor("." + strings.ToLower(name))
+}
+
+// commentMatcher finds comments in the file read line by line, it tracks block comments
+// and regions in other languages.
+type commentMatcher struct {
+	root   *LanguageDefinition
+	syntax Syntax
+
+	// lang is the language of the current region, nil if the region language is unknown.
+	lang   *LanguageDefinition
+	region *regionParsed
+	block  *BlockComment
+}
+
+func newCommentMatcher(lang *LanguageDefinition, syntax Syntax) *commentMatcher {
+	return &commentMatcher{
+		root:   lang,
+		syntax: syntax,
+		lang:   lang,
+	}
+}
+
+// match checks whether the line is a comment and returns its text along with the language
+// of the comment.
+func (m *commentMatcher) match(line []byte) ([]byte, *LanguageDefinition, bool) {
+	if block := m.block; block != nil {
+		text := line
+		if end := bytes.Index(line, []byte(block.End)); end >= 0 {
+			text = line[:end]
+			m.block = nil
+		}
+		text = bytes.TrimSpace(text)
+		if strings.HasSuffix(block.Start, "*") && bytes.HasPrefix(text, []byte("*")) {
+			// leading asterisks of /* */ comment lines
+			text = bytes.TrimSpace(text[1:])
+		}
+		return text, m.lang, true
+	}
+	if m.region != nil {
+		if m.region.end.Match(line) {
+			m.region = nil
+			m.lang = m.root
+			return nil, nil, false
+		}
+	} else if region, lang, ok := m.regionStart(line); ok {
+		m.region = region
+		m.lang = lang
+		return nil, nil, false
+	}
+	if m.lang == nil {
+		return nil, nil, false
+	}
+	// block comments are checked first, as they may start with a line comment, e.g. --[[ in Lua
+	trimmed := bytes.TrimLeft(line, " \t")
+	for i := range m.lang.BlockComments {
+		block := &m.lang.BlockComments[i]
+		if !bytes.HasPrefix(trimmed, []byte(block.Start)) {
+			continue
+		}
+		text := trimmed[len(block.Start):]
+		if end := bytes.Index(text, []byte(block.End)); end >= 0 {
+			return bytes.TrimSpace(text[:end]), m.lang, true
+		}
+		m.block = block
+		return bytes.TrimSpace(text), m.lang, true
+	}
+	if clean, ok := matchComment(m.lang, m.syntax, line); ok {
+		return clean, m.lang, true
+	}
+	return nil, nil, false
+}
+
+func (m *commentMatcher) regionStart(line []byte) (*regionParsed, *LanguageDefinition, bool) {
+	for i := range m.root.regionsParsed {
+		region := &m.root.regionsParsed[i]
+		match := region.start.FindSubmatchIndex(line)
+		if match == nil {
+			continue
+		} else if region.end.Match(line[match[1]:]) {
+			// the region ends on the same line, e.g. <script src="app.js"></script>
+			continue
+		}
+		name := region.Language
+		for j, group := range region.start.SubexpNames() {
+			if group == "lang" && match[2*j] >= 0 && match[2*j+1] > match[2*j] {
+				name = string(line[match[2*j]:match[2*j+1]])
+			}
+		}
+		if name == region.Language && region.lang != nil {
+			return region, region.lang, true
+		}
+		lang, _ := LanguageForName(name)
+		return region, lang, true
+	}
+	return nil, nil, false
+}
@@ -0,0 +1,111 @@
+package parser
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// conformanceDocument is the part of the cc-go JSON document with crumbs, grouping of trails
+// is not checked there as it's done by cc-go rather than the parser.
+type conformanceDocument struct {
+	MainTrails map[string][]*CodeCrumb `json:"main_trails"`
+	SideTrails map[string][]*CodeCrumb `json:"side_trails"`
+	Remarks    []*CodeCrumb            `json:"remarks"`
+}
+
+func (d *conformanceDocument) crumbs() []*CodeCrumb {
+	var list []*CodeCrumb
+	for _, trail := range d.MainTrails {
+		list = append(list, trail...)
+	}
+	for _, trail := range d.SideTrails {
+		list = append(list, trail...)
+	}
+	return append(list, d.Remarks...)
+}
+
+// TestConformance parses the sources of testdata/syntax/<syntax>/src and compares the crumbs
+// with the expected.json document, that is also checked by make conformance.
+func TestConformance(t *testing.T) {
+	for _, syntax := range Syntaxes {
+		syntax := Syntax(syntax)
+		t.Run(string(syntax), func(t *testing.T) {
+			dir := filepath.Join("testdata", "syntax", string(syntax))
+			data, err := ioutil.ReadFile(filepath.Join(dir, "expected.json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected conformanceDocument
+			if err := json.Unmarshal(data, &expected); err != nil {
+				t.Fatal(err)
+			}
+
+			var collected []*CodeCrumb
+			srcDir := filepath.Join(dir, "src")
+			err = filepath.Walk(srcDir, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				lang, ok := LanguageForFile(info.Name())
+				if !ok {
+					return nil
+				}
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				relativePath, err := filepath.Rel(srcDir, path)
+				if err != nil {
+					return err
+				}
+				crumbs, err := CollectCrumbs(filepath.ToSlash(relativePath), lang, syntax, f)
+				if err != nil {
+					t.Errorf("%s: %v", relativePath, err)
+					return nil
+				}
+				collected = append(collected, crumbs...)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// crumbs are compared as they appear in JSON, e.g. PeekNum is not saved
+			data, err = json.Marshal(collected)
+			if err != nil {
+				t.Fatal(err)
+			}
+			collected = nil
+			if err := json.Unmarshal(data, &collected); err != nil {
+				t.Fatal(err)
+			}
+
+			want, got := sortedCrumbs(expected.crumbs()), sortedCrumbs(collected)
+			if len(want) != len(got) {
+				t.Errorf("expected %d crumbs, got %d", len(want), len(got))
+			}
+			for i := 0; i < len(want) && i < len(got); i++ {
+				if !reflect.DeepEqual(want[i], got[i]) {
+					w, _ := json.MarshalIndent(want[i], "", "\t")
+					g, _ := json.MarshalIndent(got[i], "", "\t")
+					t.Errorf("crumb %d differs\nexpected: %s\ngot: %s", i, w, g)
+				}
+			}
+		})
+	}
+}
+
+func sortedCrumbs(list []*CodeCrumb) []*CodeCrumb {
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].SourcePath != list[j].SourcePath {
+			return list[i].SourcePath < list[j].SourcePath
+		}
+		return list[i].SourceLine < list[j].SourceLine
+	})
+	return list
+}
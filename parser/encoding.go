@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrBinaryFile is returned for files that don't look like text, such files should be skipped.
+var ErrBinaryFile = errors.New("binary file")
+
+// sniffLen is the size of the file head used to detect its encoding, the same as git uses
+// to tell binary files apart.
+const sniffLen = 8000
+
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// newTextReader detects encoding of the text by its head and returns a reader of the text in UTF-8.
+// UTF-16 is detected by BOM or by zero bytes of ASCII characters, the UTF-8 BOM is stripped. Files
+// with NUL bytes in other encodings are considered binary.
+func newTextReader(r io.Reader) (*bufio.Reader, error) {
+	br := bufio.NewReaderSize(r, sniffLen)
+	head, err := br.Peek(sniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		_, err := br.Discard(len(bomUTF8))
+		return br, err
+	case bytes.HasPrefix(head, bomUTF16LE):
+		br.Discard(len(bomUTF16LE))
+		return decodeUTF16(br, binary.LittleEndian)
+	case bytes.HasPrefix(head, bomUTF16BE):
+		br.Discard(len(bomUTF16BE))
+		return decodeUTF16(br, binary.BigEndian)
+	}
+	if order, ok := sniffUTF16(head); ok {
+		return decodeUTF16(br, order)
+	}
+	if bytes.IndexByte(head, 0) >= 0 {
+		return nil, ErrBinaryFile
+	}
+	return br, nil
+}
+
+// sniffUTF16 detects UTF-16 text without BOM: most of its characters are ASCII, so every
+// other byte is zero.
+func sniffUTF16(head []byte) (binary.ByteOrder, bool) {
+	pairs := len(head) / 2
+	if pairs < 2 {
+		return nil, false
+	}
+	var zeroEven, zeroOdd int
+	for i := 0; i+1 < len(head); i += 2 {
+		if head[i] == 0 {
+			zeroEven++
+		}
+		if head[i+1] == 0 {
+			zeroOdd++
+		}
+	}
+	switch {
+	case zeroOdd*10 > pairs*4 && zeroEven*20 < pairs:
+		return binary.LittleEndian, true
+	case zeroEven*10 > pairs*4 && zeroOdd*20 < pairs:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+func decodeUTF16(r io.Reader, order binary.ByteOrder) (*bufio.Reader, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = order.Uint16(data[2*i:])
+	}
+	buf := new(bytes.Buffer)
+	buf.Grow(len(units))
+	for _, r := range utf16.Decode(units) {
+		buf.WriteRune(r)
+	}
+	return bufio.NewReader(buf), nil
+}
+
+// readLine reads the next line of any length, without the line ending. Lines that aren't
+// valid UTF-8 are decoded from Latin-1. A read error is returned along with the partial line,
+// io.EOF is returned only when there are no more lines.
+func readLine(r *bufio.Reader) ([]byte, error) {
+	line, err := r.ReadBytes('\n')
+	if len(line) == 0 && err != nil {
+		return nil, err
+	} else if err == io.EOF {
+		// the last line without the line ending
+		err = nil
+	}
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if !utf8.Valid(line) {
+		line = decodeLatin1(line)
+	}
+	return line, err
+}
+
+func decodeLatin1(line []byte) []byte {
+	buf := make([]byte, 0, len(line)*2)
+	for _, b := range line {
+		buf = append(buf, string(rune(b))...)
+	}
+	return buf
+}
@@ -1,16 +1,29 @@
 package parser
 
 import (
+	"path"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
 
 type LanguageDefinition struct {
-	Name       string
+	Name string
+	// Fence is the language name used for code blocks in Markdown.
+	Fence      string
 	Extensions []string
-	Regexps    []string
+	// Filenames are exact names of files in the language, e.g. Dockerfile.
+	Filenames []string
+	// Interpreters are names of programs in the shebang line of extensionless scripts.
+	Interpreters []string
+	Regexps      []string
+	// BlockComments are used along with the line comments matched by Regexps.
+	BlockComments []BlockComment
+	// Regions are parts of the file in other languages, scanned with their own comment rules.
+	Regions []Region
 
 	regexpsParsed []*regexp.Regexp
+	regionsParsed []regionParsed
 }
 
 func (def *LanguageDefinition) Match(lineBytes []byte) ([]byte, bool) {
@@ -30,10 +43,52 @@ func LanguageFor(ext string) (*LanguageDefinition, bool) {
 	return nil, false
 }
 
+// LanguageForFile detects the language by the exact file name or by its extension.
+func LanguageForFile(name string) (*LanguageDefinition, bool) {
+	if def, ok := filenamesCache[name]; ok {
+		return &def, ok
+	}
+	return LanguageFor(filepath.Ext(name))
+}
+
+var shebangRx = regexp.MustCompile(`^#!\s*(\S+)(?:\s+(?:-\S+\s+)*(\S+))?`)
+
+// LanguageForShebang detects the language of a script by the interpreter in its shebang line,
+// e.g. #!/bin/bash or #!/usr/bin/env python3.
+func LanguageForShebang(line []byte) (*LanguageDefinition, bool) {
+	m := shebangRx.FindSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+	interpreter := path.Base(string(m[1]))
+	if interpreter == "env" {
+		interpreter = path.Base(string(m[2]))
+	}
+	// versioned interpreters like python3.8 are matched by the name
+	interpreter = strings.TrimRight(interpreter, "0123456789.")
+	if def, ok := interpretersCache[interpreter]; ok {
+		return &def, ok
+	}
+	return nil, false
+}
+
+// FenceFor returns the Markdown code block language for the language name.
+func FenceFor(name string) string {
+	for _, def := range SupportedLangs {
+		if def.Name == name && len(def.Fence) > 0 {
+			return def.Fence
+		}
+	}
+	return strings.ToLower(name)
+}
+
 func init() {
 	langsCache = make(map[string]LanguageDefinition)
+	filenamesCache = make(map[string]LanguageDefinition)
+	interpretersCache = make(map[string]LanguageDefinition)
 	addLang(LanguageDefinition{
-		Name: "Go",
+		Name:  "Go",
+		Fence: "go",
 		Extensions: []string{
 			".go",
 		},
@@ -42,7 +97,8 @@ func init() {
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "Solidity",
+		Name:  "Solidity",
+		Fence: "solidity",
 		Extensions: []string{
 			".sol",
 		},
@@ -51,43 +107,80 @@ func init() {
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "Javascript",
+		Name:  "Javascript",
+		Fence: "javascript",
 		Extensions: []string{
-			".js", ".jsx",
+			".js", ".jsx", ".mjs", ".cjs",
+		},
+		Interpreters: []string{
+			"node",
 		},
 		Regexps: []string{
 			`^\s*//\s?`,
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "Typescript",
+		Name:  "Typescript",
+		Fence: "typescript",
 		Extensions: []string{
-			".ts",
+			".ts", ".tsx", ".mts", ".cts",
+		},
+		Interpreters: []string{
+			"deno", "ts-node",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
 		},
+	})
+	// PHP code is registered separately from .php files, which may contain HTML
+	// outside of <?php ?> tags; the code regions and fenced PHP samples are scanned
+	// using this definition.
+	phpCode := addLang(LanguageDefinition{
+		Name:  "PHP",
+		Fence: "php",
 		Regexps: []string{
 			`^\s*//\s?`,
+			`^\s*#\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "PHP",
+		Name:  "PHP",
+		Fence: "php",
 		Extensions: []string{
-			".php",
+			".php", ".phtml",
 		},
-		Regexps: []string{
-			`^\s*//\s?`,
+		Interpreters: []string{
+			"php",
+		},
+		BlockComments: []BlockComment{
+			{"<!--", "-->"},
 		},
+		Regions: append([]Region{{
+			Start:    `<\?php\b`,
+			End:      `\?>`,
+			Language: "PHP",
+			lang:     phpCode,
+		}}, htmlRegions...),
 	})
 	addLang(LanguageDefinition{
-		Name: "Python",
+		Name:  "Python",
+		Fence: "python",
 		Extensions: []string{
 			".py",
 		},
+		Interpreters: []string{
+			"python",
+		},
 		Regexps: []string{
 			`^\s*#\s?`,
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "YAML",
+		Name:  "YAML",
+		Fence: "yaml",
 		Extensions: []string{
 			".yml", ".yaml",
 		},
@@ -96,7 +189,8 @@ func init() {
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "Java",
+		Name:  "Java",
+		Fence: "java",
 		Extensions: []string{
 			".java",
 		},
@@ -105,26 +199,353 @@ func init() {
 		},
 	})
 	addLang(LanguageDefinition{
-		Name: "C/C++",
+		Name:  "C/C++",
+		Fence: "cpp",
+		Extensions: []string{
+			".c", ".h", ".cpp", ".cxx", ".cc", ".hpp", ".objc", ".m",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Rust",
+		Fence: "rust",
 		Extensions: []string{
-			".c", ".h", ".cpp", ".cxx", ".objc", ".m",
+			".rs",
 		},
 		Regexps: []string{
 			`^\s*//\s?`,
 		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Ruby",
+		Fence: "ruby",
+		Extensions: []string{
+			".rb", ".rake", ".gemspec",
+		},
+		Filenames: []string{
+			"Gemfile", "Rakefile",
+		},
+		Interpreters: []string{
+			"ruby",
+		},
+		Regexps: []string{
+			`^\s*#\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"=begin", "=end"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Shell",
+		Fence: "sh",
+		Extensions: []string{
+			".sh", ".bash", ".zsh",
+		},
+		Interpreters: []string{
+			"sh", "bash", "zsh", "dash", "ksh",
+		},
+		Regexps: []string{
+			`^\s*#\s?`,
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "SQL",
+		Fence: "sql",
+		Extensions: []string{
+			".sql",
+		},
+		Regexps: []string{
+			`^\s*--\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Kotlin",
+		Fence: "kotlin",
+		Extensions: []string{
+			".kt", ".kts",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Swift",
+		Fence: "swift",
+		Extensions: []string{
+			".swift",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Lua",
+		Fence: "lua",
+		Extensions: []string{
+			".lua",
+		},
+		Interpreters: []string{
+			"lua",
+		},
+		Regexps: []string{
+			`^\s*--\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"--[[", "]]"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Protobuf",
+		Fence: "protobuf",
+		Extensions: []string{
+			".proto",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Terraform",
+		Fence: "hcl",
+		Extensions: []string{
+			".tf", ".tfvars", ".hcl",
+		},
+		Regexps: []string{
+			`^\s*#\s?`,
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Dockerfile",
+		Fence: "dockerfile",
+		Extensions: []string{
+			".dockerfile",
+		},
+		Filenames: []string{
+			"Dockerfile", "Containerfile",
+		},
+		Regexps: []string{
+			`^\s*#\s?`,
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Makefile",
+		Fence: "makefile",
+		Extensions: []string{
+			".mk", ".mak",
+		},
+		Filenames: []string{
+			"Makefile", "GNUmakefile", "makefile",
+		},
+		Interpreters: []string{
+			"make",
+		},
+		Regexps: []string{
+			`^\s*#\s?`,
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Groovy",
+		Fence: "groovy",
+		Extensions: []string{
+			".groovy", ".gradle",
+		},
+		Filenames: []string{
+			"Jenkinsfile",
+		},
+		Interpreters: []string{
+			"groovy",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Perl",
+		Fence: "perl",
+		Extensions: []string{
+			".pl", ".pm",
+		},
+		Interpreters: []string{
+			"perl",
+		},
+		Regexps: []string{
+			`^\s*#\s?`,
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Markdown",
+		Fence: "markdown",
+		Extensions: []string{
+			".md", ".markdown",
+		},
+		BlockComments: []BlockComment{
+			{"<!--", "-->"},
+		},
+		Regions: []Region{{
+			Start: "^\\s*(?:```+|~~~+)\\s*(?P<lang>[\\w+#.-]*)",
+			End:   "^\\s*(?:```+|~~~+)\\s*$",
+		}},
+	})
+	addLang(LanguageDefinition{
+		Name:  "HTML",
+		Fence: "html",
+		Extensions: []string{
+			".html", ".htm",
+		},
+		BlockComments: []BlockComment{
+			{"<!--", "-->"},
+		},
+		Regions: htmlRegions,
+	})
+	addLang(LanguageDefinition{
+		Name:  "Vue",
+		Fence: "vue",
+		Extensions: []string{
+			".vue",
+		},
+		BlockComments: []BlockComment{
+			{"<!--", "-->"},
+		},
+		Regions: htmlRegions,
+	})
+	addLang(LanguageDefinition{
+		Name:  "Svelte",
+		Fence: "svelte",
+		Extensions: []string{
+			".svelte",
+		},
+		BlockComments: []BlockComment{
+			{"<!--", "-->"},
+		},
+		Regions: htmlRegions,
+	})
+	addLang(LanguageDefinition{
+		Name:  "CSS",
+		Fence: "css",
+		Extensions: []string{
+			".css",
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "SCSS",
+		Fence: "scss",
+		Extensions: []string{
+			".scss",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Less",
+		Fence: "less",
+		Extensions: []string{
+			".less",
+		},
+		Regexps: []string{
+			`^\s*//\s?`,
+		},
+		BlockComments: []BlockComment{
+			{"/*", "*/"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Go Template",
+		Fence: "go-html-template",
+		Extensions: []string{
+			".tmpl", ".gotmpl", ".tpl",
+		},
+		BlockComments: []BlockComment{
+			// the closing }} may be trimmed or not regardless of the opening one,
+			// e.g. {{- /* ... */}}, and is ignored after the end of comment
+			{"{{/*", "*/"},
+			{"{{- /*", "*/"},
+			{"<!--", "-->"},
+		},
+	})
+	addLang(LanguageDefinition{
+		Name:  "Jinja",
+		Fence: "jinja",
+		Extensions: []string{
+			".j2", ".jinja", ".jinja2", ".njk", ".twig",
+		},
+		BlockComments: []BlockComment{
+			{"{#", "#}"},
+			{"<!--", "-->"},
+		},
 	})
 }
 
-func addLang(def LanguageDefinition) {
+// htmlRegions are scripts and styles embedded into HTML-like markup, the language
+// is taken from the lang attribute, e.g. <script lang="ts"> in Vue components.
+var htmlRegions = []Region{{
+	Start:    `<script\b(?:[^>]*?\blang=["']?(?P<lang>[\w-]+))?[^>]*>`,
+	End:      `</script\s*>`,
+	Language: "Javascript",
+}, {
+	Start:    `<style\b(?:[^>]*?\blang=["']?(?P<lang>[\w-]+))?[^>]*>`,
+	End:      `</style\s*>`,
+	Language: "CSS",
+}}
+
+func addLang(def LanguageDefinition) *LanguageDefinition {
 	for _, rx := range def.Regexps {
 		def.regexpsParsed = append(def.regexpsParsed, regexp.MustCompile(rx))
 	}
+	for i := range def.Regions {
+		def.regionsParsed = append(def.regionsParsed, regionParsed{
+			Region: &def.Regions[i],
+			start:  regexp.MustCompile(def.Regions[i].Start),
+			end:    regexp.MustCompile(def.Regions[i].End),
+		})
+	}
 	for _, ext := range def.Extensions {
 		langsCache[strings.ToLower(ext)] = def
 	}
+	for _, name := range def.Filenames {
+		filenamesCache[name] = def
+	}
+	for _, name := range def.Interpreters {
+		interpretersCache[name] = def
+	}
 	SupportedLangs = append(SupportedLangs, def)
+	return &def
 }
 
 var SupportedLangs = []LanguageDefinition{}
 
-var langsCache map[string]LanguageDefinition
+var (
+	langsCache        map[string]LanguageDefinition
+	filenamesCache    map[string]LanguageDefinition
+	interpretersCache map[string]LanguageDefinition
+)
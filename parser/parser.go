@@ -1,15 +1,14 @@
 package parser
 
 import (
-	"bufio"
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
 	"regexp"
 	"strconv"
 
 	"github.com/google/uuid"
-	log "github.com/sirupsen/logrus"
 	"github.com/xlab/catcher"
 )
 
@@ -20,7 +19,9 @@ var (
 	prefixCC = regexp.MustCompile(`\s?(cc:|CC:)\s?`)
 )
 
-func CollectCrumbs(sourcePath string, commentLineLang *LanguageDefinition, r io.Reader) ([]*CodeCrumb, error) {
+// CollectCrumbs parses comments of the source code in the given language and
+// returns crumbs found there, markers are parsed according to the syntax.
+func CollectCrumbs(sourcePath string, commentLineLang *LanguageDefinition, syntax Syntax, r io.Reader) ([]*CodeCrumb, error) {
 	// inComment keeps mark if we are in a comments block.
 	inComment := false
 	// inCommentCC keeps mark if we are in CC'd section of a comment. The CC'd section must
@@ -29,32 +30,55 @@ func CollectCrumbs(sourcePath string, commentLineLang *LanguageDefinition, r io.
 
 	var list []*CodeCrumb
 	var current *CodeCrumb
+	// block keeps the previous crumbs of the comment block, they are submitted along with
+	// the current one when the block ends, so their peeks start after the block.
+	var block []*CodeCrumb
 
+	text, err := newTextReader(r)
+	if err != nil {
+		return nil, err
+	}
+	comments := newCommentMatcher(commentLineLang, syntax)
 	var line int
-	s := bufio.NewScanner(r)
-	for s.Scan() {
+	for {
+		lineBytes, err := readLine(text)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
 		line++
-		lineBytes := s.Bytes()
 
-		if cleanLine, ok := commentLineLang.Match(lineBytes); ok {
+		if cleanLine, lang, ok := comments.match(lineBytes); ok {
 			inComment = true
-			idx := prefixCC.FindIndex(cleanLine)
+			idx := syntax.markerIndex(cleanLine)
 			if idx != nil {
 				// found a CC comment
 				if inCommentCC {
-					err := errors.New("cannot place a CC marker in the same comment block multiple times")
-					return nil, err
+					if syntax != SyntaxCodecrumbs {
+						err := &SyntaxError{
+							Line: line,
+							Err:  errors.New("cannot place a CC marker in the same comment block multiple times"),
+						}
+						return nil, err
+					}
+					// every marker is a separate crumb in the original syntax
+					block = append(block, current)
 				}
 				inCommentCC = true
 				current = &CodeCrumb{
-					ID:           uuid.Must(uuid.NewRandom()).String(),
-					LanguageName: commentLineLang.Name,
+					ID:           crumbID(sourcePath, line),
+					LanguageName: lang.Name,
 					SourcePath:   sourcePath,
 					PeekedLines:  []string{},
 				}
-				current.ParseCC(cleanLine[idx[1]:])
+				if syntax == SyntaxCodecrumbs {
+					current.ParseCodecrumbs(cleanLine[idx[1]:])
+				} else {
+					current.ParseCC(cleanLine[idx[1]:])
+				}
 				current.SourceLine = line
-			} else if inCommentCC {
+			} else if inCommentCC && syntax != SyntaxCodecrumbs {
 				// there is not marker on this line, but we already seen it
 				current.DescLines = append(current.DescLines, string(cleanLine))
 			}
@@ -64,7 +88,9 @@ func CollectCrumbs(sourcePath string, commentLineLang *LanguageDefinition, r io.
 			if inCommentCC {
 				// had a CC part, so can sumbit it to the list
 				inCommentCC = false
-				list = append(list, current)
+				current.trimDescLines()
+				list = append(append(list, block...), current)
+				block = nil
 				current = nil
 			}
 		}
@@ -77,15 +103,29 @@ func CollectCrumbs(sourcePath string, commentLineLang *LanguageDefinition, r io.
 		}
 	}
 	if current != nil {
-		list = append(list, current)
+		current.trimDescLines()
+		list = append(append(list, block...), current)
 		current = nil
 	}
-	if err := s.Err(); err != nil {
-		log.Errorln("error during scan", err)
-	}
 	return list, nil
 }
 
+// SyntaxError is returned for misplaced crumbs, it tells the line of the problem.
+type SyntaxError struct {
+	Line int
+	Err  error
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %v", e.Line, e.Err)
+}
+
+// crumbID derives a stable ID of the crumb from its location, so the output doesn't change
+// between runs over the same code.
+func crumbID(sourcePath string, line int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s:%d", sourcePath, line))).String()
+}
+
 type CodeCrumb struct {
 	ID           string   `json:"id"`
 	Title        string   `json:"title"`
@@ -97,6 +137,24 @@ type CodeCrumb struct {
 	PeekNum      int      `json:"-"`
 	PeekedLines  []string `json:"peeked_lines"`
 	LanguageName string   `json:"lang_name"`
+	Module       string   `json:"module,omitempty"`
+	// Source is set for crumbs merged from documents of other projects, so their source links
+	// point to the right repository.
+	Source *CrumbSource `json:"source,omitempty"`
+}
+
+// CrumbSource overrides the source prefix, ref and directory of the project in source links.
+type CrumbSource struct {
+	Prefix string `json:"prefix"`
+	Ref    string `json:"ref,omitempty"`
+	Dir    string `json:"dir,omitempty"`
+}
+
+// trimDescLines removes empty lines at the end of description, e.g. left by the end of a block comment.
+func (cc *CodeCrumb) trimDescLines() {
+	for len(cc.DescLines) > 0 && len(cc.DescLines[len(cc.DescLines)-1]) == 0 {
+		cc.DescLines = cc.DescLines[:len(cc.DescLines)-1]
+	}
 }
 
 func (cc *CodeCrumb) ParseCC(line []byte) {
@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Syntax defines the grammar of codecrumb markers in comments.
+type Syntax string
+
+const (
+	// SyntaxNative is the cc-go marker syntax: the cc prefix and a colon, followed by optional
+	// trail ID and step separated by '#', the title, optional peek and description, separated
+	// by ';'. The following lines of the same comment block are added to the description.
+	SyntaxNative Syntax = "native"
+	// SyntaxCodecrumbs is the syntax of the original codecrumbs tool: the cc prefix and a colon,
+	// followed by optional flow and step separated by '#', the name, optional details and params,
+	// separated by ';'. Params may contain +N to include N lines following the comment. Every
+	// marker is a separate crumb, single-line block comments are supported as well.
+	SyntaxCodecrumbs Syntax = "codecrumbs"
+)
+
+var Syntaxes = []string{
+	string(SyntaxNative),
+	string(SyntaxCodecrumbs),
+}
+
+func ParseSyntax(name string) (Syntax, error) {
+	switch syntax := Syntax(strings.ToLower(name)); syntax {
+	case SyntaxNative, SyntaxCodecrumbs:
+		return syntax, nil
+	case "":
+		return SyntaxNative, nil
+	default:
+		return "", fmt.Errorf("unsupported marker syntax: %s", name)
+	}
+}
+
+var (
+	prefixCodecrumbs = regexp.MustCompile(`^\s*cc:\s*`)
+	blockComment     = regexp.MustCompile(`^\s*/\*+\s?(.*?)\s*\*+/\s*$`)
+	paramLinesRange  = regexp.MustCompile(`^\+(\d+)$`)
+)
+
+// markerIndex returns position of the marker in the comment text, or nil if there is no marker.
+func (s Syntax) markerIndex(comment []byte) []int {
+	if s == SyntaxCodecrumbs {
+		return prefixCodecrumbs.FindIndex(comment)
+	}
+	return prefixCC.FindIndex(comment)
+}
+
+// matchComment checks whether the line is a comment and returns its text.
+func matchComment(lang *LanguageDefinition, syntax Syntax, lineBytes []byte) ([]byte, bool) {
+	if clean, ok := lang.Match(lineBytes); ok {
+		return clean, true
+	}
+	if syntax == SyntaxCodecrumbs {
+		if m := blockComment.FindSubmatch(lineBytes); m != nil {
+			return m[1], true
+		}
+	}
+	return nil, false
+}
+
+// ParseCodecrumbs parses the marker in the original codecrumbs syntax.
+func (cc *CodeCrumb) ParseCodecrumbs(line []byte) {
+	ccParts := bytes.Split(line, separatorParts)
+	if bytes.Contains(ccParts[0], separatorTrail) {
+		trailParts := bytes.SplitN(ccParts[0], separatorTrail, 2)
+		cc.TrailID = string(bytes.TrimSpace(trailParts[0]))
+		cc.TrailStep, _ = strconv.Atoi(string(bytes.TrimSpace(trailParts[1])))
+		ccParts = ccParts[1:]
+	}
+	if len(ccParts) > 0 {
+		cc.Title = string(bytes.TrimSpace(ccParts[0]))
+	}
+	if len(ccParts) > 1 {
+		if details := bytes.TrimSpace(ccParts[1]); len(details) > 0 {
+			cc.DescLines = append(cc.DescLines, string(details))
+		}
+	}
+	if len(ccParts) > 2 {
+		for _, param := range strings.FieldsFunc(string(ccParts[2]), func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t'
+		}) {
+			if m := paramLinesRange.FindStringSubmatch(param); m != nil {
+				cc.PeekNum, _ = strconv.Atoi(m[1])
+			}
+		}
+	}
+}
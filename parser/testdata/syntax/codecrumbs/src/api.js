@@ -0,0 +1,11 @@
+//cc:signin#3;Send credentials;https://example.com/docs/auth;+3
+export function login(user, password) {
+  return fetch('/api/login', {
+    method: 'POST',
+    body: JSON.stringify({ user, password }),
+  });
+}
+
+//cc:refresh#;Step without number
+//cc:refresh#1;Token refresh;;+1,highlight
+export function refresh() {}
@@ -0,0 +1,21 @@
+import { login } from './api';
+
+//cc:signin#1;Form submitted;Validates fields before sending
+function onSubmit(form) {
+  if (!form.valid) {
+    return;
+  }
+  //cc:signin#2;Call API;See api.js for request details;+2
+  login(form.user, form.password)
+    .then(redirect);
+}
+
+//cc:Standalone remark;;+1
+//cc:Second remark in the same block;Every marker is a separate crumb
+// this line is a plain comment, not a description
+function redirect() {
+  /* cc:signin#4;Redirect;Goes to the dashboard;+1 */
+  window.location = '/dashboard';
+}
+
+// a comment mentioning cc: in the middle is not a marker
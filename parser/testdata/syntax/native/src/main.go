@@ -0,0 +1,19 @@
+package main
+
+import "fmt"
+
+// cc:boot#1;Program start;2;Entry point of the program
+func main() {
+	fmt.Println("hello")
+	run()
+}
+
+// run starts the processing.
+//
+// cc:boot#2;Run;Starts processing
+// Description continues on the next lines
+// of the same comment block.
+func run() {
+	// CC: Remark title;Remark description
+	fmt.Println("run")
+}
@@ -0,0 +1,221 @@
+package renderer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Inliner rewrites an HTML page so it doesn't depend on any external resources:
+// fonts, images and stylesheets are fetched and embedded as data URIs.
+type Inliner struct {
+	// BaseDir is used to resolve relative references to local files.
+	BaseDir string
+	// Warn is called for references that cannot be inlined, they are left as is.
+	Warn func(ref string, err error)
+
+	cli   *http.Client
+	cache map[string]string
+}
+
+func NewInliner(baseDir string) *Inliner {
+	return &Inliner{
+		BaseDir: baseDir,
+
+		cli: &http.Client{
+			Timeout: 15 * time.Second,
+		},
+		cache: make(map[string]string),
+	}
+}
+
+var (
+	stylesheetRx = regexp.MustCompile(`<link[^>]+rel="stylesheet"[^>]*>`)
+	hrefRx       = regexp.MustCompile(`\shref="([^"]+)"`)
+	styleRx      = regexp.MustCompile(`(?is)(<style[^>]*>)(.*?)(</style>)`)
+	styleAttrRx  = regexp.MustCompile(`(\sstyle)="([^"]*)"`)
+	imageTagRx   = regexp.MustCompile(`(?i)<(?:img|source|g-emoji)\s[^>]*>`)
+	srcAttrRx    = regexp.MustCompile(`(\s(?:src|fallback-src))="([^"]+)"`)
+	srcsetAttrRx = regexp.MustCompile(`(\ssrcset)="([^"]+)"`)
+	cssURLRx     = regexp.MustCompile(`url\(\s*['"]?([^'")\s]+)['"]?\s*\)`)
+)
+
+// InlineHTML embeds all resources referenced by the page: linked stylesheets become
+// <style> blocks, url() references of styles and images of img, source and g-emoji elements
+// become data URIs. The rest of the page, e.g. code blocks, scripts or frames, is not changed. References that cannot be fetched
+// are reported to Warn and left as is.
+func (in *Inliner) InlineHTML(page []byte) ([]byte, error) {
+	page = stylesheetRx.ReplaceAllFunc(page, func(tag []byte) []byte {
+		m := hrefRx.FindSubmatch(tag)
+		if m == nil {
+			return tag
+		}
+		ref := string(m[1])
+		css, _, err := in.fetch(ref)
+		if err != nil {
+			in.warn(ref, err)
+			return tag
+		}
+		css = in.inlineCSS(css, ref)
+		return append(append([]byte("<style>"), css...), "</style>"...)
+	})
+	page = styleRx.ReplaceAllFunc(page, func(style []byte) []byte {
+		m := styleRx.FindSubmatch(style)
+		return bytes.Join([][]byte{m[1], in.inlineCSS(m[2], ""), m[3]}, nil)
+	})
+	page = styleAttrRx.ReplaceAllFunc(page, func(attr []byte) []byte {
+		m := styleAttrRx.FindSubmatch(attr)
+		return []byte(fmt.Sprintf(`%s="%s"`, m[1], in.inlineCSS(m[2], "")))
+	})
+	page = imageTagRx.ReplaceAllFunc(page, func(tag []byte) []byte {
+		tag = srcAttrRx.ReplaceAllFunc(tag, func(attr []byte) []byte {
+			m := srcAttrRx.FindSubmatch(attr)
+			return []byte(fmt.Sprintf(`%s="%s"`, m[1], in.inlineRef(string(m[2]), "")))
+		})
+		return srcsetAttrRx.ReplaceAllFunc(tag, func(attr []byte) []byte {
+			m := srcsetAttrRx.FindSubmatch(attr)
+			return []byte(fmt.Sprintf(`%s="%s"`, m[1], in.inlineSrcset(string(m[2]))))
+		})
+	})
+	return page, nil
+}
+
+// inlineCSS replaces url() references found in CSS, resolving them relative to the base reference.
+func (in *Inliner) inlineCSS(css []byte, base string) []byte {
+	return cssURLRx.ReplaceAllFunc(css, func(ref []byte) []byte {
+		m := cssURLRx.FindSubmatch(ref)
+		return []byte(fmt.Sprintf("url(%s)", in.inlineRef(string(m[1]), base)))
+	})
+}
+
+// inlineSrcset replaces URLs of the image candidates, e.g. "a.png 1x, a@2x.png 2x".
+func (in *Inliner) inlineSrcset(srcset string) string {
+	if strings.Contains(srcset, "data:") {
+		// data URIs may contain commas, so candidates cannot be split
+		return srcset
+	}
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = in.inlineRef(fields[0], "")
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// inlineRef returns the data URI of the referenced resource, or the reference itself if it
+// cannot be fetched.
+func (in *Inliner) inlineRef(ref, base string) string {
+	dataURI, err := in.dataURI(ref, base)
+	if err != nil {
+		in.warn(ref, err)
+		return ref
+	}
+	return dataURI
+}
+
+func (in *Inliner) warn(ref string, err error) {
+	if in.Warn != nil {
+		in.Warn(ref, err)
+	}
+}
+
+func (in *Inliner) dataURI(ref, base string) (string, error) {
+	if strings.HasPrefix(ref, "data:") || strings.HasPrefix(ref, "#") {
+		return ref, nil
+	}
+	ref = resolveRef(ref, base)
+	if dataURI, ok := in.cache[ref]; ok {
+		return dataURI, nil
+	}
+	data, contentType, err := in.fetch(ref)
+	if err != nil {
+		return "", err
+	}
+	dataURI := fmt.Sprintf("data:%s;base64,%s", contentType, base64.StdEncoding.EncodeToString(data))
+	in.cache[ref] = dataURI
+	return dataURI, nil
+}
+
+// fetch loads a resource either from the web or from local filesystem, returning its contents
+// and the detected content type.
+func (in *Inliner) fetch(ref string) ([]byte, string, error) {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to parse resource URL %s: %v", ref, err)
+	}
+	var data []byte
+	var contentType string
+	if len(u.Scheme) == 0 && len(u.Host) > 0 {
+		// protocol-relative URL, e.g. //cdn.example.com/style.css
+		u.Scheme = "https"
+	}
+	switch u.Scheme {
+	case "http", "https":
+		req, _ := http.NewRequest("GET", u.String(), nil)
+		req.Header.Set("User-Agent", "codecrumbs-go")
+		resp, err := in.cli.Do(req)
+		if err != nil {
+			return nil, "", err
+		}
+		data, _ = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			err := fmt.Errorf("failed to fetch %s (%d)", ref, resp.StatusCode)
+			return nil, "", err
+		}
+		contentType = resp.Header.Get("Content-Type")
+	case "file", "":
+		filePath := u.Path
+		if u.Scheme == "" && !filepath.IsAbs(filePath) {
+			filePath = filepath.Join(in.BaseDir, filepath.FromSlash(filePath))
+		}
+		data, err = ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, "", err
+		}
+	default:
+		return nil, "", fmt.Errorf("unsupported resource URL scheme: %s", ref)
+	}
+	if len(contentType) == 0 || strings.HasPrefix(contentType, "application/octet-stream") ||
+		strings.HasPrefix(contentType, "text/plain") {
+		contentType = mime.TypeByExtension(path.Ext(u.Path))
+	}
+	if len(contentType) == 0 {
+		contentType = http.DetectContentType(data)
+	}
+	if idx := strings.Index(contentType, ";"); idx > 0 {
+		contentType = strings.TrimSpace(contentType[:idx])
+	}
+	return data, contentType, nil
+}
+
+func resolveRef(ref, base string) string {
+	if len(base) == 0 {
+		return ref
+	}
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		return ref
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	if len(baseURL.Scheme) == 0 && len(refURL.Scheme) == 0 && !path.IsAbs(refURL.Path) {
+		return path.Join(path.Dir(baseURL.Path), refURL.Path)
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
@@ -0,0 +1,249 @@
+package renderer
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// LocalRenderer converts Markdown into HTML without any external services. It supports
+// the subset of Markdown used by generated documents: headings, paragraphs, lists, fenced
+// code blocks, tables, quotes, links, images and emphasis. Raw HTML, e.g. from crumb
+// descriptions, is escaped.
+type LocalRenderer struct {
+	Project string
+}
+
+func NewLocalRenderer(project string) *LocalRenderer {
+	return &LocalRenderer{
+		Project: project,
+	}
+}
+
+func (r *LocalRenderer) RenderHTML(contents []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	buf.Write(pageHead)
+	fmt.Fprintf(buf, "<title>%s</title>", html.EscapeString(r.Project))
+	fmt.Fprint(buf, `</head><body><article class="markdown-body">`)
+	buf.Write(RenderMarkdown(contents))
+	fmt.Fprint(buf, "</article></body></html>")
+	return buf.Bytes(), nil
+}
+
+var (
+	headingRx     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*$`)
+	fenceRx       = regexp.MustCompile("^\\s*(```+|~~~+)\\s*([\\w+#.\\-/]*)")
+	listItemRx    = regexp.MustCompile(`^(\s*)([-*+]|\d+[.)])\s+(.*)$`)
+	ruleRx        = regexp.MustCompile(`^\s{0,3}([-*_])(\s*([-*_]))+\s*$`)
+	tableDelimRx  = regexp.MustCompile(`^\s*\|?(\s*:?-+:?\s*\|)+\s*(:?-+:?)?\s*$`)
+	anchorPunctRx = regexp.MustCompile(`[^\w\- ]`)
+)
+
+type markdownBlock struct {
+	buf        *bytes.Buffer
+	paragraph  []string
+	listStack  []string
+	anchorSeen map[string]int
+}
+
+// RenderMarkdown converts Markdown into HTML fragment, headings get GitHub-compatible anchors.
+func RenderMarkdown(contents []byte) []byte {
+	b := &markdownBlock{
+		buf:        new(bytes.Buffer),
+		anchorSeen: make(map[string]int),
+	}
+	lines := strings.Split(strings.Replace(string(contents), "\r\n", "\n", -1), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case len(trimmed) == 0:
+			b.flushParagraph()
+			if !b.listContinues(lines, i) {
+				b.closeLists(0)
+			}
+		case fenceRx.MatchString(line):
+			b.flushParagraph()
+			b.closeLists(0)
+			m := fenceRx.FindStringSubmatch(line)
+			fence, lang := m[1], m[2]
+			if len(lang) > 0 {
+				fmt.Fprintf(b.buf, `<pre lang="%s"><code>`, html.EscapeString(lang))
+			} else {
+				fmt.Fprint(b.buf, "<pre><code>")
+			}
+			for i++; i < len(lines); i++ {
+				if strings.HasPrefix(strings.TrimSpace(lines[i]), fence) {
+					break
+				}
+				fmt.Fprintf(b.buf, "%s\n", html.EscapeString(lines[i]))
+			}
+			fmt.Fprint(b.buf, "</code></pre>\n")
+		case headingRx.MatchString(line):
+			b.flushParagraph()
+			b.closeLists(0)
+			m := headingRx.FindStringSubmatch(line)
+			level := len(m[1])
+			anchor := b.headingAnchor(m[2])
+			fmt.Fprintf(b.buf, `<h%d><a id="%s" class="anchor" href="#%s"></a>%s</h%d>`+"\n",
+				level, anchor, anchor, renderInline(m[2]), level)
+		case ruleRx.MatchString(line) && len(b.paragraph) == 0:
+			b.closeLists(0)
+			fmt.Fprint(b.buf, "<hr>\n")
+		case strings.HasPrefix(trimmed, ">"):
+			b.flushParagraph()
+			b.closeLists(0)
+			var quote []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if !strings.HasPrefix(t, ">") {
+					i--
+					break
+				}
+				quote = append(quote, strings.TrimPrefix(strings.TrimPrefix(t, ">"), " "))
+			}
+			fmt.Fprintf(b.buf, "<blockquote>\n%s</blockquote>\n", RenderMarkdown([]byte(strings.Join(quote, "\n"))))
+		case strings.Contains(line, "|") && i+1 < len(lines) && tableDelimRx.MatchString(lines[i+1]):
+			b.flushParagraph()
+			b.closeLists(0)
+			fmt.Fprint(b.buf, "<table>\n<thead>\n<tr>")
+			for _, cell := range tableCells(line) {
+				fmt.Fprintf(b.buf, "<th>%s</th>", renderInline(cell))
+			}
+			fmt.Fprint(b.buf, "</tr>\n</thead>\n<tbody>\n")
+			for i += 2; i < len(lines); i++ {
+				if !strings.Contains(lines[i], "|") {
+					i--
+					break
+				}
+				fmt.Fprint(b.buf, "<tr>")
+				for _, cell := range tableCells(lines[i]) {
+					fmt.Fprintf(b.buf, "<td>%s</td>", renderInline(cell))
+				}
+				fmt.Fprint(b.buf, "</tr>\n")
+			}
+			fmt.Fprint(b.buf, "</tbody>\n</table>\n")
+		case listItemRx.MatchString(line):
+			b.flushParagraph()
+			m := listItemRx.FindStringSubmatch(line)
+			depth := len(strings.Replace(m[1], "\t", "  ", -1))/2 + 1
+			tag := "ul"
+			if !strings.ContainsAny(m[2], "-*+") {
+				tag = "ol"
+			}
+			b.closeLists(depth)
+			if len(b.listStack) < depth {
+				for len(b.listStack) < depth {
+					b.listStack = append(b.listStack, tag)
+					fmt.Fprintf(b.buf, "<%s>\n", tag)
+				}
+			} else {
+				fmt.Fprint(b.buf, "</li>\n")
+			}
+			fmt.Fprintf(b.buf, "<li>%s", renderInline(m[3]))
+		default:
+			b.paragraph = append(b.paragraph, trimmed)
+		}
+	}
+	b.flushParagraph()
+	b.closeLists(0)
+	return b.buf.Bytes()
+}
+
+func (b *markdownBlock) flushParagraph() {
+	if len(b.paragraph) == 0 {
+		return
+	}
+	text := renderInline(strings.Join(b.paragraph, "\n"))
+	if len(b.listStack) > 0 {
+		fmt.Fprintf(b.buf, "<p>%s</p>", text)
+	} else {
+		fmt.Fprintf(b.buf, "<p>%s</p>\n", text)
+	}
+	b.paragraph = nil
+}
+
+// closeLists closes nested lists deeper than depth.
+func (b *markdownBlock) closeLists(depth int) {
+	for len(b.listStack) > depth {
+		tag := b.listStack[len(b.listStack)-1]
+		b.listStack = b.listStack[:len(b.listStack)-1]
+		fmt.Fprintf(b.buf, "</li>\n</%s>\n", tag)
+	}
+}
+
+func (b *markdownBlock) listContinues(lines []string, i int) bool {
+	if len(b.listStack) == 0 {
+		return false
+	}
+	for i++; i < len(lines); i++ {
+		if len(strings.TrimSpace(lines[i])) > 0 {
+			return listItemRx.MatchString(lines[i])
+		}
+	}
+	return false
+}
+
+// headingAnchor makes anchors the same way as GitHub does, duplicates get a numeric suffix.
+func (b *markdownBlock) headingAnchor(heading string) string {
+	anchor := anchorPunctRx.ReplaceAllString(strings.ToLower(stripInline(heading)), "")
+	anchor = strings.Replace(anchor, " ", "-", -1)
+	seen := b.anchorSeen[anchor]
+	b.anchorSeen[anchor]++
+	if seen > 0 {
+		anchor = fmt.Sprintf("%s-%d", anchor, seen)
+	}
+	return anchor
+}
+
+func tableCells(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	line = strings.TrimSuffix(line, "|")
+	cells := strings.Split(line, "|")
+	for i := range cells {
+		cells[i] = strings.TrimSpace(cells[i])
+	}
+	return cells
+}
+
+var (
+	inlineCodeRx = regexp.MustCompile("`([^`]+)`")
+	imageRx      = regexp.MustCompile(`!\[([^\]]*)\]\(([^)\s]+)\)`)
+	linkRx       = regexp.MustCompile(`\[([^\]]+)\]\(([^)\s]+)\)`)
+	strongRx     = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+	emphasisRx   = regexp.MustCompile(`\*([^*\s][^*]*)\*`)
+	placeholder  = regexp.MustCompile("\x00(\\d+)\x00")
+)
+
+// renderInline renders inline Markdown elements, the text is escaped except the code spans.
+func renderInline(text string) string {
+	var codeSpans []string
+	text = inlineCodeRx.ReplaceAllStringFunc(text, func(span string) string {
+		code := inlineCodeRx.FindStringSubmatch(span)[1]
+		codeSpans = append(codeSpans, "<code>"+html.EscapeString(code)+"</code>")
+		return fmt.Sprintf("\x00%d\x00", len(codeSpans)-1)
+	})
+	text = html.EscapeString(text)
+	text = imageRx.ReplaceAllString(text, `<img src="$2" alt="$1">`)
+	text = linkRx.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	text = strongRx.ReplaceAllString(text, `<strong>$1</strong>`)
+	text = emphasisRx.ReplaceAllString(text, `<em>$1</em>`)
+	text = strings.Replace(text, "\n", "<br>\n", -1)
+	return placeholder.ReplaceAllStringFunc(text, func(p string) string {
+		var idx int
+		fmt.Sscanf(placeholder.FindStringSubmatch(p)[1], "%d", &idx)
+		return codeSpans[idx]
+	})
+}
+
+// stripInline removes inline Markdown markup, leaving the plain text.
+func stripInline(text string) string {
+	text = inlineCodeRx.ReplaceAllString(text, "$1")
+	text = imageRx.ReplaceAllString(text, "$1")
+	text = linkRx.ReplaceAllString(text, "$1")
+	text = strongRx.ReplaceAllString(text, "$1")
+	return emphasisRx.ReplaceAllString(text, "$1")
+}